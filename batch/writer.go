@@ -0,0 +1,111 @@
+// Package batch groups items written one at a time into batches, for ingestion pipelines such as
+// a log agent inserting into a database.
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Write once the Writer is closed.
+var ErrClosed = errors.New("batch writer closed")
+
+// Writer collects items into batches and hands each batch to a flush function, e.g. a bulk
+// database insert, once it holds size items or interval has passed since the last flush.
+//
+// All batches are flushed in order by a single background goroutine. When ctx is canceled or
+// Close is called, the remaining items get a final flush with a context that is not canceled.
+type Writer[T any] struct {
+	size     int
+	interval time.Duration
+	flush    func(ctx context.Context, batch []T) error
+
+	in   chan T
+	stop chan struct{}
+	done chan struct{}
+
+	closeOnce sync.Once
+	// err is the first error returned by flush, readable once done is closed.
+	err error
+}
+
+// NewWriter creates and starts a Writer. A size <= 0 flushes on the interval only, an
+// interval <= 0 on the size only. The slice passed to flush is never reused, so it may be retained.
+func NewWriter[T any](ctx context.Context, size int, interval time.Duration, flush func(ctx context.Context, batch []T) error) *Writer[T] {
+	w := &Writer[T]{
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		in:       make(chan T),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run(ctx)
+	return w
+}
+
+// Write adds item to the current batch. It blocks until the item is accepted, and returns an
+// error if ctx is canceled or the Writer is closed first.
+func (w *Writer[T]) Write(ctx context.Context, item T) error {
+	select {
+	case w.in <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return ErrClosed
+	}
+}
+
+// Close flushes the remaining items, stops the background goroutine and returns the first
+// error returned by flush. It is safe to call more than once.
+func (w *Writer[T]) Close() error {
+	w.closeOnce.Do(func() { close(w.stop) })
+	<-w.done
+	return w.err
+}
+
+// run is the background batching loop.
+func (w *Writer[T]) run(ctx context.Context) {
+	defer close(w.done)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var batch []T
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.flush(ctx, batch); err != nil && w.err == nil {
+			w.err = err
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case item := <-w.in:
+			batch = append(batch, item)
+			if w.size > 0 && len(batch) >= w.size {
+				flush(ctx)
+			}
+		case <-tick:
+			// Flush on time, so items do not linger in a slow stream.
+			flush(ctx)
+		case <-w.stop:
+			flush(context.WithoutCancel(ctx))
+			return
+		case <-ctx.Done():
+			// Canceled: flush what is left on a best-effort basis.
+			flush(context.WithoutCancel(ctx))
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder records every batch passed to flush.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (r *batchRecorder) flush(ctx context.Context, batch []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, batch)
+	return r.err
+}
+
+func (r *batchRecorder) get() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+// TestWriter_Size verifies that full batches are flushed, and the remainder on Close.
+func TestWriter_Size(t *testing.T) {
+	var rec batchRecorder
+	w := NewWriter(context.Background(), 2, 0, rec.flush)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, w.Write(context.Background(), i))
+	}
+	require.NoError(t, w.Close())
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, rec.get())
+
+	// Writes after Close fail, and closing again is safe.
+	assert.ErrorIs(t, w.Write(context.Background(), 6), ErrClosed)
+	assert.NoError(t, w.Close())
+}
+
+// TestWriter_Interval verifies that a partial batch is flushed once the interval passes.
+func TestWriter_Interval(t *testing.T) {
+	var rec batchRecorder
+	w := NewWriter(context.Background(), 100, 10*time.Millisecond, rec.flush)
+	defer w.Close()
+
+	require.NoError(t, w.Write(context.Background(), 1))
+	assert.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]int{{1}}, rec.get())
+}
+
+// TestWriter_ContextCanceled verifies that canceling ctx triggers a final flush with a live context.
+func TestWriter_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var finalCtxErr error
+	flushed := make(chan []int, 1)
+	w := NewWriter(ctx, 100, 0, func(ctx context.Context, batch []int) error {
+		finalCtxErr = ctx.Err()
+		flushed <- batch
+		return nil
+	})
+
+	require.NoError(t, w.Write(ctx, 1))
+	cancel()
+
+	assert.Equal(t, []int{1}, <-flushed)
+	assert.NoError(t, finalCtxErr)
+	assert.Error(t, w.Write(context.Background(), 2))
+	assert.NoError(t, w.Close())
+}
+
+// TestWriter_FlushError verifies that Close returns the first flush error.
+func TestWriter_FlushError(t *testing.T) {
+	rec := batchRecorder{err: errors.New("db down")}
+	w := NewWriter(context.Background(), 1, 0, rec.flush)
+
+	require.NoError(t, w.Write(context.Background(), 1))
+	require.NoError(t, w.Write(context.Background(), 2))
+	assert.EqualError(t, w.Close(), "db down")
+	assert.Len(t, rec.get(), 2)
+}
@@ -1,5 +1,15 @@
 package o11y
 
+import (
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	tc "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
 // Config is the only configuration struct in the o11y package.
 // It aggregates all configurable items for logs, traces, and metrics, and provides global metadata.
 type Config struct {
@@ -24,6 +34,17 @@ type Config struct {
 	// It's a logical unit of instrumentation. Defaults to "o11y".
 	InstrumentationScope string `yaml:"instrumentation_scope" mapstructure:"instrumentation_scope"`
 
+	// InstrumentationVersion is the version of the instrumentation scope, recorded for provenance.
+	// Defaults to the version of the o11y module found in the binary's build info.
+	InstrumentationVersion string `yaml:"instrumentation_version" mapstructure:"instrumentation_version"`
+
+	// ResourceAttributes are added to the resource describing this process, e.g.
+	// {"deployment.canary": "true", "k8s.cluster.name": "eu-1"}. They apply to every signal:
+	// spans, and metrics (as target_info labels with Prometheus). Use them for facts about the
+	// process identity that hold for its whole lifetime, such as the deployment flavor of a canary.
+	// See TraceConfig.SpanAttributes for backends that can only filter traces by span attributes.
+	ResourceAttributes map[string]string `yaml:"resource_attributes" mapstructure:"resource_attributes"`
+
 	// Log contains all configurations related to logging.
 	Log LogConfig `yaml:"log" mapstructure:"log"`
 
@@ -32,10 +53,105 @@ type Config struct {
 
 	// Metric contains all configurations related to metric statistics.
 	Metric MetricConfig `yaml:"metric" mapstructure:"metric"`
+
+	// SetGlobals controls whether Init installs its providers as the global OpenTelemetry
+	// TracerProvider and MeterProvider, and the global propagator. Set it to false when embedding
+	// o11y in an application that already owns the globals: o11y.Run, State and the o11y metrics
+	// then only use the Provider (also reachable as o11y.Tracer and o11y.Meter). Instrumentations
+	// built on the globals keep reporting to the application's providers: the otelhttp and otelgrpc
+	// spans of Handler, GRPCServerOptions and the client wrappers, the SQL and PGX wrappers, and
+	// the runtime and host metrics.
+	// It is a pointer so that an unset value defaults to true, preserving the previous behavior.
+	SetGlobals *bool `yaml:"set_globals" mapstructure:"set_globals"`
+}
+
+// DevConfig returns a preset for local development: console logging at debug level with caller
+// information, every trace sampled and printed to stdout, and metrics discarded, so nothing needs
+// to run next to the service. Fields set on the result afterwards take precedence, e.g.
+//
+//	cfg := o11y.DevConfig("order-api")
+//	cfg.Metric.Exporter = "prometheus"
+//	shutdown, err := o11y.Init(cfg)
+func DevConfig(service string) Config {
+	return Config{
+		Enabled:     true,
+		Service:     service,
+		Environment: "development",
+		Log: LogConfig{
+			Level:         "debug",
+			EnableConsole: true,
+			EnableCaller:  true,
+		},
+		Trace: TraceConfig{
+			Enabled:     true,
+			Exporter:    "stdout",
+			SampleRatio: 1,
+		},
+		Metric: MetricConfig{
+			Enabled:  true,
+			Exporter: "none",
+		},
+	}
+}
+
+// WithDefaults returns a copy of the configuration with the defaults applied by New filled in,
+// such as InstrumentationScope and PrometheusAddr. The receiver is left untouched, and the copy
+// shares no slices or maps with it, see Clone.
+func (c Config) WithDefaults() Config {
+	c = c.Clone()
+	if c.InstrumentationScope == "" {
+		c.InstrumentationScope = "o11y"
+	}
+	if c.InstrumentationVersion == "" {
+		c.InstrumentationVersion = libraryVersion()
+	}
+	if c.Metric.PrometheusAddr == "" {
+		c.Metric.PrometheusAddr = ":2222" // Default prometheus port
+	}
+	if c.Metric.PrometheusPath == "" {
+		c.Metric.PrometheusPath = "/metrics"
+	}
+	return c
+}
+
+// Clone returns a deep copy of the configuration, so it can be modified and passed to another
+// initialization without affecting the original. The span processors themselves are shared.
+func (c Config) Clone() Config {
+	c.ResourceAttributes = maps.Clone(c.ResourceAttributes)
+
+	c.SetGlobals = clonePointer(c.SetGlobals)
+	c.Log.Enabled = clonePointer(c.Log.Enabled)
+	c.Log.StackFilters = slices.Clone(c.Log.StackFilters)
+
+	c.Trace.ForceSampleTrustedNetworks = slices.Clone(c.Trace.ForceSampleTrustedNetworks)
+	c.Trace.SpanProcessors = slices.Clone(c.Trace.SpanProcessors)
+	c.Trace.BaggageSpanAttributes = slices.Clone(c.Trace.BaggageSpanAttributes)
+	c.Trace.SpanAttributes = maps.Clone(c.Trace.SpanAttributes)
+
+	c.Metric.Exporters = slices.Clone(c.Metric.Exporters)
+	c.Metric.Headers = maps.Clone(c.Metric.Headers)
+	c.Metric.DurationBuckets = slices.Clone(c.Metric.DurationBuckets)
+	c.Metric.BaggageAttributes = slices.Clone(c.Metric.BaggageAttributes)
+	c.Metric.EnableRuntimeMetrics = clonePointer(c.Metric.EnableRuntimeMetrics)
+	return c
+}
+
+// clonePointer returns a pointer to a copy of *p, or nil if p is nil.
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
 }
 
 // LogConfig defines the detailed behavior of logging.
 type LogConfig struct {
+	// Enabled controls whether logging is enabled. When false, all logs are discarded
+	// while tracing and metrics keep working. It is a pointer so that an unset value
+	// can default to true, preserving the behavior of configs written before this option.
+	Enabled *bool `yaml:"enabled" mapstructure:"enabled"`
+
 	// Level defines the global minimum log level.
 	// Optional values are "debug", "info", "warn", "error", "fatal", "panic".
 	// If set to empty or invalid value, it will default to "info".
@@ -54,10 +170,23 @@ type LogConfig struct {
 	// Enabling this option incurs a slight performance overhead; it is recommended to enable it in development environments for debugging purposes.
 	EnableCaller bool `yaml:"caller" mapstructure:"caller"`
 
+	// CallerFormat controls how the caller field is rendered; it only takes effect when EnableCaller is true.
+	// Optional values:
+	// "short": Only the file name and line number (e.g., "handler.go:42").
+	// "dir": The parent directory, file name and line number (e.g., "o11y/handler.go:42"). The directory
+	// is not always the package name, e.g. for versioned modules ("v2/client.go:42").
+	// "full": The full file path and line number (e.g., "/src/github.com/oy3o/o11y/handler.go:42").
+	// Defaults to "short".
+	CallerFormat string `yaml:"caller_format" mapstructure:"caller_format"`
+
 	// EnableConsole controls whether logs are output to standard output (stdout).
 	// Logs output to the console are typically colored and in a human-readable format.
 	EnableConsole bool `yaml:"console" mapstructure:"console"`
 
+	// ConsoleNoTimestamp omits the timestamp from console output, for environments where
+	// the container runtime or journal already timestamps each line. File output is unaffected.
+	ConsoleNoTimestamp bool `yaml:"console_no_timestamp" mapstructure:"console_no_timestamp"`
+
 	// EnableFile controls whether logs are output to a file.
 	// Logs output to a file are always in JSON format for easy machine parsing.
 	EnableFile bool `yaml:"file" mapstructure:"file"`
@@ -65,10 +194,108 @@ type LogConfig struct {
 	// FileRotation defines the log file rotation strategy; it only takes effect when EnableFile is true.
 	FileRotation FileRotationConfig `yaml:"rotation" mapstructure:"rotation"`
 
+	// FileBufferSize, if positive, buffers up to FileBufferSize bytes of file output in memory,
+	// saving a write system call per event on busy services. The buffer is flushed when full,
+	// every second and on shutdown, so events logged just before a crash can be lost; set
+	// FlushOnError to write error events and those before them right away.
+	FileBufferSize int `yaml:"file_buffer_size" mapstructure:"file_buffer_size"`
+
 	// StackFilters is a list of string prefixes used to filter out irrelevant stack frames in a panic hook.
 	// This helps clean up panic logs, allowing developers to focus on the application code itself.
 	// For example: "runtime/", "net/http".
+	// When empty, DefaultLogIgnore is used. When set, it replaces DefaultLogIgnore,
+	// unless StackFiltersAppend is true.
 	StackFilters []string `yaml:"stack_filters" mapstructure:"stack_filters"`
+
+	// StackFiltersAppend adds StackFilters to DefaultLogIgnore instead of replacing it,
+	// so custom filters keep the default ones.
+	StackFiltersAppend bool `yaml:"stack_filters_append" mapstructure:"stack_filters_append"`
+
+	// CollapseStackFrames collapses runs of the same frame in logged stack traces into one
+	// frame with a repeat count, see WithCollapsedFrames. It applies to the stacks added by
+	// StackTraceLevel and RecoveryMiddleware, keeping recursion-induced panics readable.
+	CollapseStackFrames bool `yaml:"collapse_stack_frames" mapstructure:"collapse_stack_frames"`
+
+	// StackTraceLevel is the minimum level of the log events that get the stack trace of the
+	// logging goroutine in their "stack" field, see StackHook. Set it to "error" to get stacks
+	// without logging at panic level, which makes zerolog panic after writing the event.
+	// Events that already carry a stack, such as those of RecoveryMiddleware, then hold it twice.
+	// Defaults to "panic".
+	StackTraceLevel string `yaml:"stack_trace_level" mapstructure:"stack_trace_level"`
+
+	// FlushOnError flushes buffered log writers right after every event logged at error level
+	// or above, so the event is not lost if the process crashes next, e.g. after a fatal event.
+	// It only has an effect with buffered output, i.e. FileBufferSize; the console is unbuffered.
+	FlushOnError bool `yaml:"flush_on_error" mapstructure:"flush_on_error"`
+
+	// MaxFieldLength, if positive, caps the length in bytes of the string values of log events,
+	// protecting the log pipeline from pathological payloads such as a full request body.
+	// Longer values are cut and end with "…", and the event gets a "_truncated": true field.
+	// The "stack" field is never truncated. Span attributes are capped by TraceConfig.SpanLimits.
+	// Truncated events are re-encoded, so their fields may be reordered.
+	MaxFieldLength int `yaml:"max_field_length" mapstructure:"max_field_length"`
+
+	// CrashBufferSize, if positive, keeps the last CrashBufferSize log events in memory and
+	// writes them to stderr, or to CrashDumpFile, when an event is logged at fatal or panic
+	// level, or when a panic reaches DumpLogsOnPanic. The recent log context then survives a
+	// crash even if the regular log pipeline buffered it.
+	CrashBufferSize int `yaml:"crash_buffer_size" mapstructure:"crash_buffer_size"`
+
+	// CrashDumpFile is the file the events kept by CrashBufferSize are appended to, instead of stderr.
+	CrashDumpFile string `yaml:"crash_dump_file" mapstructure:"crash_dump_file"`
+
+	// TraceIDKey and SpanIDKey are the log field names carrying the trace and span IDs,
+	// added by Run, the HTTP middlewares and the gRPC interceptors. Set them to match an
+	// existing log schema, e.g. "traceID" and "spanID". They default to "trace_id" and "span_id".
+	TraceIDKey string `yaml:"trace_id_key" mapstructure:"trace_id_key"`
+	SpanIDKey  string `yaml:"span_id_key" mapstructure:"span_id_key"`
+
+	// EnableTraceparent adds a "traceparent" field next to the trace and span IDs, holding the
+	// span context in W3C Trace Context format (e.g. "00-<trace-id>-<span-id>-01"),
+	// for correlation tools keyed on the full header rather than the bare trace ID.
+	EnableTraceparent bool `yaml:"enable_traceparent" mapstructure:"enable_traceparent"`
+}
+
+// stackFilterOptions returns the FilterStackTrace options selected by the configuration.
+func (c LogConfig) stackFilterOptions() []StackFilterOption {
+	if c.CollapseStackFrames {
+		return []StackFilterOption{WithCollapsedFrames()}
+	}
+	return nil
+}
+
+// stackFilters returns the effective stack filters. An empty result means DefaultLogIgnore.
+func (c LogConfig) stackFilters() []string {
+	if c.StackFiltersAppend && len(c.StackFilters) > 0 {
+		return slices.Concat(DefaultLogIgnore, c.StackFilters)
+	}
+	return c.StackFilters
+}
+
+// stackTraceLevel returns the effective StackTraceLevel, defaulting to panic.
+func (c LogConfig) stackTraceLevel() zerolog.Level {
+	level, err := zerolog.ParseLevel(c.StackTraceLevel)
+	if err != nil || c.StackTraceLevel == "" {
+		return zerolog.PanicLevel
+	}
+	return level
+}
+
+// idKeys returns the effective trace and span ID field names.
+func (c LogConfig) idKeys() (traceKey, spanKey string) {
+	traceKey, spanKey = c.TraceIDKey, c.SpanIDKey
+	if traceKey == "" {
+		traceKey = DefaultTraceIDKey
+	}
+	if spanKey == "" {
+		spanKey = DefaultSpanIDKey
+	}
+	return traceKey, spanKey
+}
+
+// enabled reports whether logging is enabled, defaulting to true when Enabled is unset.
+func (c LogConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
 // FileRotationConfig defines the file rotation configuration for the Lumberjack library.
@@ -87,6 +314,10 @@ type FileRotationConfig struct {
 
 	// Compress controls whether to use gzip compression for rotated old log files.
 	Compress bool `yaml:"compress" mapstructure:"compress"`
+
+	// RotateOnSIGHUP installs a SIGHUP handler that rotates the log file (see RotateLogs),
+	// for compatibility with external tools like logrotate. Defaults to false.
+	RotateOnSIGHUP bool `yaml:"rotate_on_sighup" mapstructure:"rotate_on_sighup"`
 }
 
 // TraceConfig defines the configuration for distributed tracing.
@@ -98,13 +329,23 @@ type TraceConfig struct {
 	// Optional values:
 	// "otlp-grpc": Sends data to the OpenTelemetry Collector via gRPC (recommended).
 	// "stdout": Prints tracing data to standard output in a human-readable format for debugging.
+	// "file": Writes tracing data to FilePath as JSON lines, for offline analysis without a collector.
 	// "none": Enables the tracing API but discards all data for testing.
 	Exporter string `yaml:"exporter" mapstructure:"exporter"`
 
 	// Endpoint is the target address of the OTLP Exporter, used only when the Exporter is "otlp-grpc".
 	// The format is usually "hostname:port", for example, "otel-collector:4317".
+	// A Unix domain socket can be used with "unix:///path/to/socket", e.g. for sidecar collectors;
+	// such connections usually don't use TLS, so OtlpInsecure should typically be set as well.
 	Endpoint string `yaml:"endpoint" mapstructure:"endpoint"`
 
+	// FilePath is the file spans are written to, used only when the Exporter is "file".
+	FilePath string `yaml:"file_path" mapstructure:"file_path"`
+
+	// FileRotation defines the rotation of FilePath, used only when the Exporter is "file".
+	// Its Filename and RotateOnSIGHUP fields are ignored.
+	FileRotation FileRotationConfig `yaml:"file_rotation" mapstructure:"file_rotation"`
+
 	// OtlpInsecure controls whether the OTLP gRPC client connection should be insecure.
 	// Set to true for local development when TLS is not available. Defaults to false.
 	OtlpInsecure bool `yaml:"otlp_insecure" mapstructure:"otlp_insecure"`
@@ -114,6 +355,76 @@ type TraceConfig struct {
 	// 0.5 means sampling 50% of the traces.
 	// 0.0 means not sampling any traces.
 	SampleRatio float64 `yaml:"sample_ratio" mapstructure:"sample_ratio" validate:"min=0,max=1"`
+
+	// ForceSampleHeader names an HTTP request header (e.g. "X-O11y-Force-Sample") that forces the
+	// request's trace to be sampled regardless of SampleRatio, for debugging. Empty disables it.
+	// Only trusted requests may force sampling: at least one of ForceSampleSecret and
+	// ForceSampleTrustedNetworks must be set, otherwise the header is ignored.
+	ForceSampleHeader string `yaml:"force_sample_header" mapstructure:"force_sample_header"`
+
+	// ForceSampleSecret, if set, must be the exact value of the ForceSampleHeader.
+	// Without a secret, the header value must be a true boolean such as "1" or "true".
+	ForceSampleSecret string `yaml:"force_sample_secret" mapstructure:"force_sample_secret"`
+
+	// ForceSampleTrustedNetworks, if set, restricts forced sampling to clients whose remote
+	// address is within one of these CIDRs, e.g. "10.0.0.0/8". Proxy headers are not consulted.
+	ForceSampleTrustedNetworks []string `yaml:"force_sample_trusted_networks" mapstructure:"force_sample_trusted_networks"`
+
+	// IDGenerator, if set, generates the trace and span IDs instead of the default random source.
+	// Since ratio-based sampling is decided from the trace ID, a deterministic generator
+	// (see NewDeterministicIDGenerator) makes sampling decisions reproducible in tests.
+	// It cannot be set from a configuration file.
+	IDGenerator tc.IDGenerator `yaml:"-" mapstructure:"-"`
+
+	// SpanProcessors are custom processors added to the tracer provider, after the built-in ones
+	// and before the exporter, to enrich spans centrally. OnStart receives a writable span, e.g.
+	// to set attributes derived from its name; OnEnd receives the finished, read-only span, e.g.
+	// to record metrics from its duration. Shutdown and ForceFlush are called with the provider's.
+	// They cannot be set from a configuration file.
+	SpanProcessors []tc.SpanProcessor `yaml:"-" mapstructure:"-"`
+
+	// SpanLimits caps the amount of data recorded on each span, protecting the export pipeline
+	// from pathological instrumentation (e.g. a multi-megabyte attribute).
+	SpanLimits SpanLimitsConfig `yaml:"span_limits" mapstructure:"span_limits"`
+
+	// BaggageSpanAttributes lists the baggage keys (e.g. "tenant_id") copied onto every span
+	// started in a context carrying them, as attributes of the same name. This makes values set
+	// upstream searchable at the trace level. Only listed keys are copied, since baggage is
+	// client-controlled and may hold arbitrary data.
+	BaggageSpanAttributes []string `yaml:"baggage_span_attributes" mapstructure:"baggage_span_attributes"`
+
+	// BaggageSpanAttributesDefault is the value of the attributes of BaggageSpanAttributes absent
+	// from the baggage, so every span carries them. Defaults to "unknown", like BaggageAttributesDefault.
+	BaggageSpanAttributesDefault string `yaml:"baggage_span_attributes_default" mapstructure:"baggage_span_attributes_default"`
+
+	// SpanAttributes are set on every span started by this process, e.g. {"deployment.canary": "true"}.
+	// Prefer Config.ResourceAttributes for process-wide facts, which cost nothing per span;
+	// use SpanAttributes when the tracing backend can only search or filter by span attributes.
+	SpanAttributes map[string]string `yaml:"span_attributes" mapstructure:"span_attributes"`
+
+	// DebugBufferSize, if positive, keeps up to this many running and recently finished spans
+	// in memory, served as JSON by DebugTracesHandler. Useful in development without a tracing backend.
+	DebugBufferSize int `yaml:"debug_buffer_size" mapstructure:"debug_buffer_size"`
+
+	// skipGlobals is set from Config.SetGlobals by Config.traceConfig.
+	skipGlobals bool
+}
+
+// SpanLimitsConfig defines the per-span limits applied by the tracer.
+// A zero value selects the default; a negative value removes the limit.
+// Attributes, events and links beyond the limits are dropped, and longer values are truncated.
+type SpanLimitsConfig struct {
+	// MaxAttributes is the maximum number of attributes per span. Defaults to 128.
+	MaxAttributes int `yaml:"max_attributes" mapstructure:"max_attributes"`
+
+	// MaxAttributeValueLength is the maximum length of string attribute values. Defaults to 1024.
+	MaxAttributeValueLength int `yaml:"max_attribute_value_length" mapstructure:"max_attribute_value_length"`
+
+	// MaxEvents is the maximum number of events per span. Defaults to 128.
+	MaxEvents int `yaml:"max_events" mapstructure:"max_events"`
+
+	// MaxLinks is the maximum number of links per span. Defaults to 128.
+	MaxLinks int `yaml:"max_links" mapstructure:"max_links"`
 }
 
 // MetricConfig defines the configuration for metric statistics.
@@ -124,18 +435,157 @@ type MetricConfig struct {
 	// Exporter defines the method for exporting metrics.
 	// Optional values:
 	// "prometheus": Exposes an HTTP endpoint for the Prometheus service to pull data (recommended).
+	// "otlp-grpc": Periodically pushes metrics to an OpenTelemetry Collector at Endpoint.
 	// "none": Enables the metrics API but discards all data.
 	Exporter string `yaml:"exporter" mapstructure:"exporter"`
 
+	// Exporters lists additional exporters, with the same values as Exporter, that run alongside it,
+	// e.g. ["otlp-grpc"] next to "prometheus" while migrating from scraping to pushing.
+	// An exporter that fails to initialize is logged and skipped, as long as one of them succeeds.
+	Exporters []string `yaml:"exporters" mapstructure:"exporters"`
+
+	// Endpoint is the target address of the OTLP Exporter, used only with the "otlp-grpc" exporter,
+	// in the same format as TraceConfig.Endpoint. When empty, the trace endpoint and its
+	// OtlpInsecure setting are used, matching single-collector setups; when both are empty,
+	// the standard OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string `yaml:"endpoint" mapstructure:"endpoint"`
+
+	// OtlpInsecure controls whether the OTLP gRPC client connection should be insecure.
+	// It is ignored when Endpoint is empty and the trace endpoint is used.
+	OtlpInsecure bool `yaml:"otlp_insecure" mapstructure:"otlp_insecure"`
+
+	// Headers are sent with every OTLP export, e.g. an authentication token for a hosted collector.
+	// They are never logged.
+	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
+
 	// PrometheusPath is the HTTP path exposed by the Prometheus Exporter, used only when the Exporter is "prometheus".
 	// The default and common value is "/metrics".
 	PrometheusPath string `yaml:"prometheus_path" mapstructure:"prometheus_path"`
 
 	// PrometheusAddr is the address (host:port) on which the Prometheus metrics server will listen.
-	// Defaults to ":2222".
+	// Defaults to ":2222". Port 0 picks a free port, see MetricsServerAddr.
 	PrometheusAddr string `yaml:"prometheus_addr" mapstructure:"prometheus_addr"`
 
+	// PrometheusNamespace is prepended to the names of all metrics exposed by the Prometheus Exporter,
+	// e.g. "myapp" turns "http_server_request_total" into "myapp_http_server_request_total".
+	PrometheusNamespace string `yaml:"prometheus_namespace" mapstructure:"prometheus_namespace"`
+
+	// PrometheusWithoutTargetInfo stops the Prometheus Exporter from exposing the resource
+	// as the "target_info" metric, for setups that already attach such labels at scrape time.
+	PrometheusWithoutTargetInfo bool `yaml:"prometheus_without_target_info" mapstructure:"prometheus_without_target_info"`
+
+	// PrometheusWithoutScopeInfo stops the Prometheus Exporter from adding the "otel_scope_*"
+	// labels to every metric, keeping label sets compatible with existing queries.
+	PrometheusWithoutScopeInfo bool `yaml:"prometheus_without_scope_info" mapstructure:"prometheus_without_scope_info"`
+
+	// Temporality defines the aggregation temporality of exported metrics.
+	// Optional values:
+	// "cumulative": Values accumulate since the process start, as Prometheus expects (default).
+	// "delta": Values reset after every export, as expected by backends like CloudWatch or Datadog.
+	//          Counters and histograms are exported as deltas, up-down counters stay cumulative.
+	//          It applies to the "otlp-grpc" exporter; the "prometheus" exporter only
+	//          supports "cumulative".
+	Temporality string `yaml:"temporality" mapstructure:"temporality"`
+
+	// DurationBuckets are the histogram bucket boundaries, in seconds, of every duration
+	// histogram (unit "s"), such as http.server.request.duration. Defaults to DefaultDurationBuckets.
+	// OpenTelemetry has no Prometheus summary (client-side quantiles) equivalent, so percentiles
+	// are computed from these buckets, e.g. with histogram_quantile; pick boundaries close to
+	// the latencies you alert on, since quantiles are interpolated within a bucket.
+	DurationBuckets []float64 `yaml:"duration_buckets" mapstructure:"duration_buckets"`
+
+	// BaggageAttributes lists the baggage keys (e.g. "region") added as attributes of the same name
+	// to every metric recorded with a context carrying them, such as the HTTP server metrics and
+	// those recorded through State. Attributes passed explicitly take precedence. Keep the list short
+	// and the values bounded: baggage is client-controlled, and every value is a new time series.
+	BaggageAttributes []string `yaml:"baggage_attributes" mapstructure:"baggage_attributes"`
+
+	// BaggageAttributesDefault is the value of the attributes of BaggageAttributes absent from
+	// the baggage, keeping the label sets of a metric consistent. Defaults to "unknown".
+	BaggageAttributesDefault string `yaml:"baggage_attributes_default" mapstructure:"baggage_attributes_default"`
+
+	// ServiceAttributes adds Config.Service, Version and Environment as the service.name,
+	// service.version and deployment.environment.name attributes of every metric recorded
+	// through the registry, for backends that do not turn resource attributes into labels,
+	// e.g. Prometheus, where they are only found on target_info. Empty values are left out.
+	// Leave it off when the resource attributes already reach the backend, as the labels would
+	// then be doubled.
+	ServiceAttributes bool `yaml:"service_attributes" mapstructure:"service_attributes"`
+
+	// RecordRunOverhead records o11y.run.overhead.duration, the time every o11y.Run spends outside
+	// the wrapped function. It costs two clock reads and a histogram record per call, so it is
+	// meant for measuring the library's cost, e.g. in a load test, rather than for production.
+	RecordRunOverhead bool `yaml:"record_run_overhead" mapstructure:"record_run_overhead"`
+
 	// EnableHostMetrics controls whether to automatically collect host metrics (e.g., CPU, memory).
 	// If true, the library will start a collector for host metrics upon initialization.
 	EnableHostMetrics bool `yaml:"enable_host_metrics" mapstructure:"enable_host_metrics"`
+
+	// EnableRuntimeMetrics controls whether to automatically collect Go runtime metrics
+	// (e.g., goroutines, GC, memory), independently of host and standard metrics.
+	// It is a pointer so that an unset value defaults to true, preserving the previous behavior.
+	EnableRuntimeMetrics *bool `yaml:"enable_runtime_metrics" mapstructure:"enable_runtime_metrics"`
+
+	// skipGlobals is set from Config.SetGlobals by Config.metricConfig.
+	skipGlobals bool
+
+	// serviceAttrs are the attributes of ServiceAttributes, set by Config.metricConfig.
+	serviceAttrs []attribute.KeyValue
+}
+
+// setGlobals reports whether the global OpenTelemetry providers are set, defaulting to true
+// when SetGlobals is unset.
+func (c Config) setGlobals() bool {
+	return c.SetGlobals == nil || *c.SetGlobals
+}
+
+// traceConfig returns the trace configuration passed to setupTracing.
+func (c Config) traceConfig() TraceConfig {
+	t := c.Trace
+	t.skipGlobals = !c.setGlobals()
+	return t
+}
+
+// metricConfig returns the metric configuration, with the OTLP endpoint falling back to
+// the trace endpoint when unset.
+func (c Config) metricConfig() MetricConfig {
+	m := c.Metric
+	m.skipGlobals = !c.setGlobals()
+	if m.ServiceAttributes {
+		for _, kv := range []attribute.KeyValue{
+			semconv.ServiceName(c.Service),
+			semconv.ServiceVersion(c.Version),
+			semconv.DeploymentEnvironmentName(c.Environment),
+		} {
+			if kv.Value.AsString() != "" {
+				m.serviceAttrs = append(m.serviceAttrs, kv)
+			}
+		}
+	}
+	if m.Endpoint == "" {
+		m.Endpoint = c.Trace.Endpoint
+		m.OtlpInsecure = c.Trace.OtlpInsecure
+	}
+	return m
+}
+
+// exporters returns the deduplicated list of configured exporters, defaulting to "none"
+// when no other exporter is set.
+func (c MetricConfig) exporters() []string {
+	var names []string
+	for _, name := range append([]string{c.Exporter}, c.Exporters...) {
+		if name != "" && name != "none" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	return names
+}
+
+// runtimeMetricsEnabled reports whether Go runtime metrics are collected, defaulting to true
+// when EnableRuntimeMetrics is unset.
+func (c MetricConfig) runtimeMetricsEnabled() bool {
+	return c.EnableRuntimeMetrics == nil || *c.EnableRuntimeMetrics
 }
@@ -0,0 +1,67 @@
+package o11y
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InitFromFile loads the configuration from a YAML or JSON file with LoadConfigFile
+// and initializes o11y with it. It removes the config-loading boilerplate from services.
+//
+// Usage:
+//
+//	shutdown, err := o11y.InitFromFile("config.yaml")
+//	if err != nil {
+//	    log.Fatal().Err(err).Msg("Failed to initialize o11y")
+//	}
+//	defer shutdown(context.Background())
+func InitFromFile(path string) (ShutdownFunc, error) {
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Init(cfg)
+}
+
+// LoadConfigFile reads a Config from a file. The format is detected by extension:
+// ".yaml"/".yml" for YAML and ".json" for JSON. Both use the same keys as the `yaml` struct tags.
+//
+// Two layouts are supported: the Config at the top level of the document, or nested under
+// an "o11y" key as part of an application's main configuration file (see example/server/config.yaml).
+// If the document has a top-level "o11y" key, the nested layout is assumed.
+func LoadConfigFile(path string) (Config, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+		// JSON is a subset of YAML, so a single decoder handles both
+		// while honoring the `yaml` struct tags for JSON documents too.
+	default:
+		return Config{}, fmt.Errorf("unsupported config file extension %q: expected .yaml, .yml or .json", ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc struct {
+		O11y yaml.Node `yaml:"o11y"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var cfg Config
+	if doc.O11y.Kind != 0 {
+		err = doc.O11y.Decode(&cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to decode config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
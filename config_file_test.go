@@ -0,0 +1,82 @@
+package o11y
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "Top_level_yaml",
+			file: "o11y.yaml",
+			content: `
+enabled: true
+service: "file-service"
+instrumentation_scope: "file.scope"
+trace:
+  sample_ratio: 0.5
+`,
+		},
+		{
+			name: "Nested_yaml",
+			file: "app.yml",
+			content: `
+port: 8080
+o11y:
+  enabled: true
+  service: "file-service"
+  instrumentation_scope: "file.scope"
+  trace:
+    sample_ratio: 0.5
+`,
+		},
+		{
+			name:    "Top_level_json",
+			file:    "o11y.json",
+			content: `{"enabled": true, "service": "file-service", "instrumentation_scope": "file.scope", "trace": {"sample_ratio": 0.5}}`,
+		},
+		{
+			name:    "Nested_json",
+			file:    "app.json",
+			content: `{"port": 8080, "o11y": {"enabled": true, "service": "file-service", "instrumentation_scope": "file.scope", "trace": {"sample_ratio": 0.5}}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o644))
+
+			cfg, err := LoadConfigFile(path)
+			require.NoError(t, err)
+			assert.True(t, cfg.Enabled)
+			assert.Equal(t, "file-service", cfg.Service)
+			assert.Equal(t, "file.scope", cfg.InstrumentationScope)
+			assert.Equal(t, 0.5, cfg.Trace.SampleRatio)
+		})
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadConfigFile(filepath.Join(dir, "config.toml"))
+	assert.ErrorContains(t, err, "unsupported config file extension")
+
+	_, err = LoadConfigFile(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read config file")
+
+	bad := filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("service: [unclosed"), 0o644))
+	_, err = LoadConfigFile(bad)
+	assert.ErrorContains(t, err, "failed to parse config file")
+}
@@ -0,0 +1,168 @@
+package o11y
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextAttributesKey is the context key under which WithContextAttributes stores attributes.
+type contextAttributesKey struct{}
+
+// WithContextAttributes returns a new Context carrying the given attributes in addition to
+// any attributes already stored by an outer call. Every span started by o11y.Run with this
+// Context (or a Context derived from it) is tagged with the accumulated set, so a constant
+// attribute like workflow_id reaches all nested operations without manual plumbing.
+// On key collision, the attribute added last wins.
+//
+// The attributes are only applied to spans, never to metrics, so high-cardinality values
+// such as IDs are acceptable. Keep the set small, though: it is copied onto every span
+// started beneath this Context and inflates export payloads accordingly.
+//
+// Example:
+//
+//	ctx = o11y.WithContextAttributes(ctx, attribute.String("workflow_id", id))
+//	err := o11y.Run(ctx, "ProcessWorkflow", fn)
+func WithContextAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	existing := ContextAttributes(ctx)
+	merged := make([]attribute.KeyValue, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, contextAttributesKey{}, merged)
+}
+
+// ContextAttributes returns the attributes stored in the Context by WithContextAttributes.
+// The returned slice must not be modified.
+func ContextAttributes(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(contextAttributesKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// providerKey is the context key under which ContextWithProvider stores a *Provider.
+type providerKey struct{}
+
+// ContextWithProvider returns a new Context carrying p. o11y.Run prefers the Tracer of a
+// Provider found in the Context over the package-level Tracer set by Init, and
+// GetLoggerFromContext falls back to its Logger when the Context has no logger of its own.
+// This enables multi-provider setups and tests that don't mutate package state.
+//
+// Metrics always use the global provider: those recorded by Run itself and through State
+// (State.IncCounter, State.RecordHistogram, ...) go to the instruments registered on the
+// package-level Meter, whatever Provider the Context carries.
+func ContextWithProvider(ctx context.Context, p *Provider) context.Context {
+	return context.WithValue(ctx, providerKey{}, p)
+}
+
+// ProviderFromContext returns the Provider stored in the Context by ContextWithProvider, if any.
+func ProviderFromContext(ctx context.Context) (*Provider, bool) {
+	p, ok := ctx.Value(providerKey{}).(*Provider)
+	return p, ok && p != nil
+}
+
+// stateKey is the context key under which o11y.Run stores the State of the current operation.
+type stateKey struct{}
+
+// FromContext returns the State of the innermost o11y.Run operation the Context was derived from.
+// It lets deeply nested functions record events and metrics against the current operation
+// without threading State through every signature.
+//
+// Example:
+//
+//	func chargeCard(ctx context.Context) {
+//	    if s, ok := o11y.FromContext(ctx); ok {
+//	        s.AddEvent("card.charged")
+//	    }
+//	}
+func FromContext(ctx context.Context) (State, bool) {
+	s, ok := ctx.Value(stateKey{}).(State)
+	return s, ok
+}
+
+// loggerSpanKey is the context key of the spanLogger recording the span whose trace_id and
+// span_id fields the context logger already carries, as injected by LoggerMiddleware and Run.
+type loggerSpanKey struct{}
+
+// spanLogger records a context logger carrying the trace and span ID fields of a span.
+type spanLogger struct {
+	spanID trace.SpanID
+
+	// logger is the logger stored in the context, to tell whether it was replaced since.
+	logger *zerolog.Logger
+
+	// base is the logger the fields were added to.
+	base zerolog.Logger
+}
+
+// contextWithSpanLogger stores base, with the IDs of sc and the operation field, if not empty,
+// added, in ctx. It returns the new context and the stored logger.
+func contextWithSpanLogger(ctx context.Context, base zerolog.Logger, sc trace.SpanContext, operation string) (context.Context, zerolog.Logger) {
+	c := withSpanFields(base.With(), sc)
+	if operation != "" {
+		c = c.Str("operation", operation)
+	}
+	logger := c.Logger()
+	ctx = logger.WithContext(ctx)
+	return context.WithValue(ctx, loggerSpanKey{}, spanLogger{spanID: sc.SpanID(), logger: zerolog.Ctx(ctx), base: base}), logger
+}
+
+// uncorrelatedLogger returns the logger of ctx, as returned by GetLoggerFromContext, without
+// the fields added by contextWithSpanLogger, so those of another span can be added without
+// duplicating the JSON keys.
+func uncorrelatedLogger(ctx context.Context) zerolog.Logger {
+	if sl, ok := ctx.Value(loggerSpanKey{}).(spanLogger); ok && sl.logger == zerolog.Ctx(ctx) {
+		return sl.base
+	}
+	return *GetLoggerFromContext(ctx)
+}
+
+// correlatedLogger returns the logger of ctx, as returned by GetLoggerFromContext, with the
+// trace and span ID fields of the span in ctx, unless the logger already carries them.
+func correlatedLogger(ctx context.Context) zerolog.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return *GetLoggerFromContext(ctx)
+	}
+	if sl, ok := ctx.Value(loggerSpanKey{}).(spanLogger); ok && sl.spanID == sc.SpanID() {
+		return *GetLoggerFromContext(ctx)
+	}
+	return withSpanFields(uncorrelatedLogger(ctx).With(), sc).Logger()
+}
+
+// withSpanFields adds the trace and span IDs of sc to c, under the configured field names,
+// and the traceparent field if LogConfig.EnableTraceparent is set.
+func withSpanFields(c zerolog.Context, sc trace.SpanContext) zerolog.Context {
+	fields := loadSpanFields()
+	c = c.
+		Str(fields.traceIDKey, sc.TraceID().String()).
+		Str(fields.spanIDKey, sc.SpanID().String())
+	if fields.traceparent {
+		c = c.Str("traceparent", traceparent(sc))
+	}
+	return c
+}
+
+// traceparent formats sc as a W3C traceparent header value.
+func traceparent(sc trace.SpanContext) string {
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// LinkFromCarrier extracts a span context from carrier using the global propagator and returns
+// a link to it, for use with WithLinks. This correlates an async operation with the trace that
+// originated it, e.g. one whose context was injected into a job payload with
+// otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier)).
+// If the carrier holds no valid span context, the returned zero link is ignored by WithLinks.
+func LinkFromCarrier(carrier map[string]string) trace.Link {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return trace.Link{}
+	}
+	return trace.Link{SpanContext: sc}
+}
@@ -0,0 +1,123 @@
+package o11y
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	tc "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// debugSpans is the buffer served by DebugTracesHandler, or nil if TraceConfig.DebugBufferSize is not set.
+var debugSpans atomic.Pointer[spanBuffer]
+
+// DebugTracesHandler returns an HTTP handler listing the spans kept in memory when
+// TraceConfig.DebugBufferSize is set: the spans still running and the most recently finished ones,
+// newest first, in the JSON format of the "file" and "stdout" exporters. The "trace_id" query
+// parameter restricts the listing to one trace. It responds 404 if the buffer is disabled.
+//
+// It is meant for local development, where running a collector and a tracing backend is overkill.
+// Do not expose it publicly: spans may contain sensitive attributes.
+//
+// Example:
+//
+//	mux.Handle("/debug/traces", o11y.DebugTracesHandler())
+func DebugTracesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := debugSpans.Load()
+		if buf == nil {
+			http.Error(w, "debug trace buffer is disabled, set trace.debug_buffer_size to enable it", http.StatusNotFound)
+			return
+		}
+
+		active, recent := buf.snapshot()
+		if traceID := r.URL.Query().Get("trace_id"); traceID != "" {
+			active, recent = filterTrace(active, traceID), filterTrace(recent, traceID)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(struct {
+			Active tracetest.SpanStubs `json:"active"`
+			Recent tracetest.SpanStubs `json:"recent"`
+		}{Active: active, Recent: recent})
+	})
+}
+
+// filterTrace returns the spans of the given trace.
+func filterTrace(spans tracetest.SpanStubs, traceID string) tracetest.SpanStubs {
+	return slices.DeleteFunc(spans, func(s tracetest.SpanStub) bool {
+		return s.SpanContext.TraceID().String() != traceID
+	})
+}
+
+// spanBuffer is a SpanProcessor keeping the running spans and a ring buffer of the last finished ones.
+// At most size spans of each kind are kept; spans started while size spans are running are not listed.
+type spanBuffer struct {
+	mu     sync.Mutex
+	size   int
+	active map[trace.SpanID]tc.ReadOnlySpan
+	// recent is a ring buffer; next is the index of the oldest entry once it is full.
+	recent []tc.ReadOnlySpan
+	next   int
+}
+
+// newSpanBuffer creates a spanBuffer keeping up to size spans.
+func newSpanBuffer(size int) *spanBuffer {
+	return &spanBuffer{
+		size:   size,
+		active: make(map[trace.SpanID]tc.ReadOnlySpan),
+		recent: make([]tc.ReadOnlySpan, 0, size),
+	}
+}
+
+// OnStart tracks s as running.
+func (b *spanBuffer) OnStart(parent context.Context, s tc.ReadWriteSpan) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.active) < b.size {
+		b.active[s.SpanContext().SpanID()] = s
+	}
+}
+
+// OnEnd moves s from the running spans to the ring buffer, evicting the oldest finished span if full.
+func (b *spanBuffer) OnEnd(s tc.ReadOnlySpan) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.active, s.SpanContext().SpanID())
+	if len(b.recent) < b.size {
+		b.recent = append(b.recent, s)
+		return
+	}
+	b.recent[b.next] = s
+	b.next = (b.next + 1) % b.size
+}
+
+func (*spanBuffer) Shutdown(ctx context.Context) error   { return nil }
+func (*spanBuffer) ForceFlush(ctx context.Context) error { return nil }
+
+// snapshot returns the running spans, oldest first, and the finished spans, newest first.
+func (b *spanBuffer) snapshot() (active, recent tracetest.SpanStubs) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	active = make(tracetest.SpanStubs, 0, len(b.active))
+	for _, s := range b.active {
+		active = append(active, tracetest.SpanStubFromReadOnlySpan(s))
+	}
+	slices.SortFunc(active, func(a, b tracetest.SpanStub) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+
+	recent = make(tracetest.SpanStubs, 0, len(b.recent))
+	for i := range b.recent {
+		// Walk backwards from the newest entry, just before next.
+		j := (b.next - 1 - i + 2*len(b.recent)) % len(b.recent)
+		recent = append(recent, tracetest.SpanStubFromReadOnlySpan(b.recent[j]))
+	}
+	return active, recent
+}
@@ -0,0 +1,73 @@
+package o11y
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// debugTraces fetches DebugTracesHandler and returns the names of the listed spans.
+func debugTraces(t *testing.T, query string) (status int, active, recent []string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	DebugTracesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/traces"+query, nil))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil, nil
+	}
+
+	var body struct {
+		Active []struct{ Name string }
+		Recent []struct{ Name string }
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	for _, s := range body.Active {
+		active = append(active, s.Name)
+	}
+	for _, s := range body.Recent {
+		recent = append(recent, s.Name)
+	}
+	return rec.Code, active, recent
+}
+
+func TestDebugTracesHandler(t *testing.T) {
+	cfg := TraceConfig{Enabled: true, Exporter: "none", SampleRatio: 1, DebugBufferSize: 2}
+	tp, shutdown, err := setupTracing(cfg, resource.Default())
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	ctx, running := tracer.Start(context.Background(), "running")
+	for _, name := range []string{"first", "second", "third"} {
+		_, span := tracer.Start(ctx, name)
+		span.End()
+	}
+
+	status, active, recent := debugTraces(t, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"running"}, active)
+	// The buffer keeps the two newest finished spans, newest first.
+	assert.Equal(t, []string{"third", "second"}, recent)
+
+	running.End()
+	_, active, recent = debugTraces(t, "?trace_id="+running.SpanContext().TraceID().String())
+	assert.Empty(t, active)
+	assert.Equal(t, []string{"running", "third"}, recent)
+
+	_, _, recent = debugTraces(t, "?trace_id=00000000000000000000000000000001")
+	assert.Empty(t, recent)
+}
+
+func TestDebugTracesHandler_Disabled(t *testing.T) {
+	_, shutdown, err := setupTracing(TraceConfig{Enabled: true, Exporter: "none"}, resource.Default())
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	status, _, _ := debugTraces(t, "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,146 @@
+package o11y
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Dependencies declares the external dependencies of a service, so they are all opened with
+// the instrumentation of this package in one place: tracing, and connection pool metrics for
+// databases. Create it with Instrument, declare each dependency by name, then call Open.
+//
+// Example:
+//
+//	deps, err := o11y.Instrument().
+//	    SQL("primary", "postgres", primaryDSN).
+//	    PGX("analytics", analyticsDSN, o11y.WithPoolLimits(2, 10)).
+//	    HTTPClient("payments", nil).
+//	    Open(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	provider.OnShutdown(func(ctx context.Context) { deps.Close() })
+//	db := deps.DB("primary")
+type Dependencies struct {
+	sqls        []sqlDependency
+	pgxs        []pgxDependency
+	httpClients []httpClientDependency
+}
+
+type sqlDependency struct {
+	name, driverName, dsn string
+}
+
+type pgxDependency struct {
+	name, dsn string
+	opts      []PGXOption
+}
+
+type httpClientDependency struct {
+	name      string
+	transport http.RoundTripper
+}
+
+// Instrument starts the declaration of the dependencies of a service.
+func Instrument() *Dependencies {
+	return &Dependencies{}
+}
+
+// SQL declares a database/sql pool opened with OpenSQL, whose connection pool statistics are
+// registered with RegisterDBStatsMetrics under name.
+func (d *Dependencies) SQL(name, driverName, dsn string) *Dependencies {
+	d.sqls = append(d.sqls, sqlDependency{name: name, driverName: driverName, dsn: dsn})
+	return d
+}
+
+// PGX declares a PostgreSQL pool opened with OpenPGXPool.
+func (d *Dependencies) PGX(name, dsn string, opts ...PGXOption) *Dependencies {
+	d.pgxs = append(d.pgxs, pgxDependency{name: name, dsn: dsn, opts: opts})
+	return d
+}
+
+// HTTPClient declares an HTTP client created with NewHTTPClient on top of transport,
+// or http.DefaultTransport if nil.
+func (d *Dependencies) HTTPClient(name string, transport http.RoundTripper) *Dependencies {
+	d.httpClients = append(d.httpClients, httpClientDependency{name: name, transport: transport})
+	return d
+}
+
+// Open opens every declared dependency. If one fails, those already opened are closed
+// and the error names the failing dependency.
+func (d *Dependencies) Open(ctx context.Context) (*Clients, error) {
+	c := &Clients{
+		dbs:         make(map[string]*sql.DB, len(d.sqls)),
+		pools:       make(map[string]*pgxpool.Pool, len(d.pgxs)),
+		httpClients: make(map[string]*http.Client, len(d.httpClients)),
+	}
+
+	for _, dep := range d.sqls {
+		db, err := OpenSQL(dep.driverName, dep.dsn)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to open SQL dependency %s: %w", dep.name, err)
+		}
+		RegisterDBStatsMetrics(db, dep.name)
+		c.dbs[dep.name] = db
+	}
+
+	for _, dep := range d.pgxs {
+		pool, err := OpenPGXPool(ctx, dep.dsn, dep.opts...)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to open PGX dependency %s: %w", dep.name, err)
+		}
+		c.pools[dep.name] = pool
+	}
+
+	for _, dep := range d.httpClients {
+		c.httpClients[dep.name] = NewHTTPClient(dep.transport)
+	}
+
+	return c, nil
+}
+
+// Clients holds the instrumented clients opened by Dependencies.Open, by name.
+type Clients struct {
+	dbs         map[string]*sql.DB
+	pools       map[string]*pgxpool.Pool
+	httpClients map[string]*http.Client
+}
+
+// DB returns the database/sql pool declared with Dependencies.SQL, or nil if there is none.
+func (c *Clients) DB(name string) *sql.DB {
+	return c.dbs[name]
+}
+
+// PGX returns the PostgreSQL pool declared with Dependencies.PGX, or nil if there is none.
+func (c *Clients) PGX(name string) *pgxpool.Pool {
+	return c.pools[name]
+}
+
+// HTTPClient returns the HTTP client declared with Dependencies.HTTPClient, or nil if there is none.
+func (c *Clients) HTTPClient(name string) *http.Client {
+	return c.httpClients[name]
+}
+
+// Close closes the database pools and the idle connections of the HTTP clients.
+func (c *Clients) Close() error {
+	var errs error
+	for name, db := range c.dbs {
+		if err := db.Close(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to close SQL dependency %s: %w", name, err))
+		}
+	}
+	for _, pool := range c.pools {
+		pool.Close()
+	}
+	for _, client := range c.httpClients {
+		client.CloseIdleConnections()
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package o11y
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencies(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	deps, err := Instrument().
+		SQL("primary", "o11y-fake", "").
+		HTTPClient("payments", nil).
+		Open(context.Background())
+	require.NoError(t, err)
+	defer deps.Close()
+
+	db := deps.DB("primary")
+	require.NotNil(t, db)
+	var v string
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT 1").Scan(&v))
+	assert.Equal(t, "ok", v)
+
+	client := deps.HTTPClient("payments")
+	require.NotNil(t, client)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Nil(t, deps.DB("missing"))
+	assert.Nil(t, deps.PGX("missing"))
+}
+
+func TestDependencies_OpenError(t *testing.T) {
+	_, err := Instrument().
+		SQL("primary", "o11y-fake", "").
+		PGX("analytics", "://not a dsn").
+		Open(context.Background())
+	assert.ErrorContains(t, err, "failed to open PGX dependency analytics")
+}
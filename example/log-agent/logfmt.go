@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// decodeLogfmt 将一行 logfmt (key=value key2="value 2") 解码为与 JSON 解码一致的 map。
+// 未加引号的数字会被解码为 json.Number，以便复用 JSON 路径中的时间戳精度检测；
+// 没有值的裸 key 按 logfmt 约定视为 true。
+func decodeLogfmt(line []byte) (map[string]any, error) {
+	rawLog := make(map[string]any)
+	err := scanLogfmt(line, func(key string, value any, bare bool) {
+		rawLog[key] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rawLog, nil
+}
+
+// isLogfmt 判断一行是否为 logfmt：能够被完整解析，第一个 token 是 key=value 形式，
+// 且 key=value 多于裸 key。普通文本会被解析为一串裸 key，因此需要排除。
+func isLogfmt(line []byte) bool {
+	pairs, bares := 0, 0
+	err := scanLogfmt(line, func(key string, value any, bare bool) {
+		if bare {
+			bares++
+		} else if bares == 0 || pairs > 0 {
+			pairs++
+		}
+	})
+	return err == nil && pairs > bares
+}
+
+// scanLogfmt 逐个扫描 logfmt 键值对，并对每一对调用 fn。
+func scanLogfmt(line []byte, fn func(key string, value any, bare bool)) error {
+	i, n := 0, len(line)
+	for {
+		// 跳过分隔空白
+		for i < n && isLogfmtSpace(line[i]) {
+			i++
+		}
+		if i >= n {
+			return nil
+		}
+
+		// 读取 key
+		start := i
+		for i < n && line[i] != '=' && !isLogfmtSpace(line[i]) {
+			if line[i] == '"' {
+				return fmt.Errorf("unexpected quote in key at offset %d", i)
+			}
+			i++
+		}
+		if i == start {
+			return fmt.Errorf("missing key at offset %d", i)
+		}
+		key := string(line[start:i])
+
+		// 裸 key
+		if i >= n || line[i] != '=' {
+			fn(key, true, true)
+			continue
+		}
+		i++ // 跳过 '='
+
+		// 带引号的值，支持 Go 风格的转义字符
+		if i < n && line[i] == '"' {
+			start = i
+			i++
+			for i < n && line[i] != '"' {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= n {
+				return fmt.Errorf("unterminated quoted value for key %q", key)
+			}
+			i++ // 跳过结尾的 '"'
+			value, err := strconv.Unquote(string(line[start:i]))
+			if err != nil {
+				return fmt.Errorf("invalid quoted value for key %q: %w", key, err)
+			}
+			fn(key, value, false)
+			continue
+		}
+
+		// 未加引号的值
+		start = i
+		for i < n && !isLogfmtSpace(line[i]) {
+			i++
+		}
+		fn(key, logfmtValue(string(line[start:i])), false)
+	}
+}
+
+// logfmtValue 将未加引号的值转换为与 JSON(UseNumber) 一致的类型
+func logfmtValue(raw string) any {
+	if raw != "" && (raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9')) && json.Valid([]byte(raw)) {
+		return json.Number(raw)
+	}
+	return raw
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
@@ -13,6 +13,8 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+
+	"github.com/oy3o/o11y/batch"
 )
 
 // Config 定义 Agent 的运行配置
@@ -20,6 +22,10 @@ type Config struct {
 	LogPattern string // 日志文件匹配模式，例如 "logs/*.log"
 	BatchSize  int    // 批量写入数据库的大小
 	DryRun     bool   // 如果为 true，仅打印到控制台，不写入数据库
+
+	MaxLineSize int    // 单行日志的最大字节数，超长的行会被跳过并告警
+	TraceKey    string // trace ID 的字段名，需与 o11y.LogConfig.TraceIDKey 一致
+	SpanKey     string // span ID 的字段名，需与 o11y.LogConfig.SpanIDKey 一致
 }
 
 func main() {
@@ -31,6 +37,9 @@ func main() {
 	flag.StringVar(&cfg.LogPattern, "pattern", "../logs/*.log", "Glob pattern for log files to ingest")
 	flag.IntVar(&cfg.BatchSize, "batch", 100, "Batch size for database insertion")
 	flag.BoolVar(&cfg.DryRun, "dry-run", true, "Print parsed logs to stdout instead of inserting into DB")
+	flag.IntVar(&cfg.MaxLineSize, "max-line-size", DefaultMaxLineSize, "Maximum size in bytes of a log line; longer lines are skipped with a warning")
+	flag.StringVar(&cfg.TraceKey, "trace-key", DefaultTraceKey, "Field name of the trace ID in log lines")
+	flag.StringVar(&cfg.SpanKey, "span-key", DefaultSpanKey, "Field name of the span ID in log lines")
 	flag.Parse()
 
 	log.Info().Msgf("Starting Log Agent. Pattern: %s, DryRun: %v", cfg.LogPattern, cfg.DryRun)
@@ -70,7 +79,11 @@ func main() {
 			defer wgProducers.Done()
 			log.Info().Str("file", f).Msg("Parsing file...")
 			// 调用 parser.go 中的 ParseLogFile
-			ParseLogFile(f, entriesChan)
+			ParseLogFile(f, entriesChan, ParseOptions{
+				MaxLineSize: cfg.MaxLineSize,
+				TraceKey:    cfg.TraceKey,
+				SpanKey:     cfg.SpanKey,
+			})
 			log.Info().Str("file", f).Msg("Finished parsing file")
 		}(file)
 	}
@@ -104,14 +117,8 @@ func main() {
 
 // runConsumer 模拟数据库批量写入逻辑
 func runConsumer(ctx context.Context, cfg Config, ch <-chan *LogEntry) {
-	var batch []*LogEntry
-
 	// 模拟数据库插入的函数
-	flushBatch := func() {
-		if len(batch) == 0 {
-			return
-		}
-
+	flushBatch := func(ctx context.Context, batch []*LogEntry) error {
 		if cfg.DryRun {
 			// DryRun 模式：简单打印统计信息和第一条数据
 			log.Info().Int("batch_size", len(batch)).Msg("Simulating DB Insert")
@@ -122,36 +129,23 @@ func runConsumer(ctx context.Context, cfg Config, ch <-chan *LogEntry) {
 				batch[0].Message,
 			)
 		} else {
-			// 真实模式：这里应该调用 gorm.DB.Create(&batch)
+			// 真实模式：这里应该调用 gorm.DB.WithContext(ctx).Create(&batch)
 			// db.CreateInBatches(batch, 100)
 			log.Info().Int("count", len(batch)).Msg("Inserted records into Database")
 		}
-
-		// 清空缓冲区
-		batch = batch[:0] // keep capacity
+		return nil
 	}
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case entry, ok := <-ch:
-			if !ok {
-				// Channel closed, flush remaining and exit
-				flushBatch()
-				return
-			}
-			batch = append(batch, entry)
-			if len(batch) >= cfg.BatchSize {
-				flushBatch()
-			}
-		case <-ticker.C:
-			// 定时刷新，防止数据滞留
-			flushBatch()
-		case <-ctx.Done():
-			// 上下文取消，尽最大努力刷新
-			flushBatch()
+	// 按数量或每秒定时刷新，ctx 取消或 channel 关闭时刷新剩余数据
+	writer := batch.NewWriter(ctx, cfg.BatchSize, 1*time.Second, flushBatch)
+	defer func() {
+		if err := writer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to insert records into Database")
+		}
+	}()
+
+	for entry := range ch {
+		if err := writer.Write(ctx, entry); err != nil {
 			return
 		}
 	}
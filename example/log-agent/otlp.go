@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// ToOTLPLogRecord 将日志条目转换为 OTLP 日志记录，以便把历史文件日志转发到 OTLP 管道，
+// 并保留与 trace 的关联:
+//   - Trace/Span 十六进制字符串解析为 16/8 字节的 TraceId/SpanId，
+//     无法解析时原样保留在 "trace"/"span" 属性中
+//   - Level 映射为 SeverityNumber 和 SeverityText，Message 作为 Body
+//   - Service/Version/Environment 等元数据按 OTel 语义约定作为属性写入，
+//     因为单条记录不携带 Resource；Attributes 按键名排序后追加
+func (e *LogEntry) ToOTLPLogRecord() *logspb.LogRecord {
+	record := &logspb.LogRecord{
+		SeverityNumber: logspb.SeverityNumber(e.SeverityNumber()),
+		SeverityText:   e.Level,
+		Body:           stringValue(e.Message),
+	}
+	if !e.Timestamp.IsZero() {
+		record.TimeUnixNano = uint64(e.Timestamp.UnixNano())
+	}
+
+	var attrs []*commonpb.KeyValue
+	addString := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, &commonpb.KeyValue{Key: key, Value: stringValue(value)})
+		}
+	}
+
+	if id, ok := decodeID(e.Trace, 16); ok {
+		record.TraceId = id
+	} else {
+		addString("trace", e.Trace)
+	}
+	if id, ok := decodeID(e.Span, 8); ok {
+		record.SpanId = id
+	} else {
+		addString("span", e.Span)
+	}
+
+	addString("service.name", e.Service)
+	addString("service.version", e.Version)
+	addString("deployment.environment.name", e.Environment)
+	addString("module", e.Module)
+	addString("user.id", e.User)
+	if e.Caller != nil {
+		addString("caller", *e.Caller)
+	}
+	if e.Error != nil {
+		addString("exception.message", *e.Error)
+	}
+	if e.Stack != nil {
+		addString("exception.stacktrace", *e.Stack)
+	}
+
+	// map 的遍历顺序是随机的，排序后输出稳定
+	keys := make([]string, 0, len(e.Attributes))
+	for key := range e.Attributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if value := anyValue(e.Attributes[key]); value != nil {
+			attrs = append(attrs, &commonpb.KeyValue{Key: key, Value: value})
+		}
+	}
+
+	record.Attributes = attrs
+	return record
+}
+
+// decodeID 解析十六进制的 trace/span ID，长度不符或全零时返回 false
+func decodeID(s string, size int) ([]byte, bool) {
+	if len(s) != size*2 {
+		return nil, false
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil || !slices.ContainsFunc(id, func(b byte) bool { return b != 0 }) {
+		return nil, false
+	}
+	return id, true
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+// anyValue 将解析得到的属性值（见 normalizeNumbers）转换为 OTLP 的 AnyValue，nil 返回 nil
+func anyValue(value any) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return stringValue(v)
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []any:
+		values := make([]*commonpb.AnyValue, 0, len(v))
+		for _, item := range v {
+			if item := anyValue(item); item != nil {
+				values = append(values, item)
+			}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{
+			ArrayValue: &commonpb.ArrayValue{Values: values},
+		}}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		kvs := make([]*commonpb.KeyValue, 0, len(v))
+		for _, key := range keys {
+			if item := anyValue(v[key]); item != nil {
+				kvs = append(kvs, &commonpb.KeyValue{Key: key, Value: item})
+			}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+			KvlistValue: &commonpb.KeyValueList{Values: kvs},
+		}}
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// TestLogEntry_ToOTLPLogRecord 测试解析后的日志转换为带 trace 关联的 OTLP 日志记录
+func TestLogEntry_ToOTLPLogRecord(t *testing.T) {
+	p := NewLogFileParser()
+	entry, err := p.ParseLine([]byte(`{"level":"error","service":"order-api","time":1763461800123,` +
+		`"trace":"4bf92f3577b34da6a3ce929d0e0e4736","span":"00f067aa0ba902b7",` +
+		`"message":"payment failed","error":"card declined","order_id":42,"retry":true,` +
+		`"tags":["a","b"],"http":{"status":502}}`))
+	require.NoError(t, err)
+
+	record := entry.ToOTLPLogRecord()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(record.TraceId))
+	assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(record.SpanId))
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, record.SeverityNumber)
+	assert.Equal(t, "error", record.SeverityText)
+	assert.Equal(t, uint64(1763461800123)*1e6, record.TimeUnixNano)
+	assert.Equal(t, "payment failed", record.Body.GetStringValue())
+
+	attrs := make(map[string]*commonpb.AnyValue)
+	var keys []string
+	for _, kv := range record.Attributes {
+		attrs[kv.Key] = kv.Value
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"service.name", "exception.message", "http", "order_id", "retry", "tags"}, keys)
+	assert.Equal(t, "order-api", attrs["service.name"].GetStringValue())
+	assert.Equal(t, "card declined", attrs["exception.message"].GetStringValue())
+	assert.Equal(t, int64(42), attrs["order_id"].GetIntValue())
+	assert.True(t, attrs["retry"].GetBoolValue())
+	assert.Len(t, attrs["tags"].GetArrayValue().Values, 2)
+	status := attrs["http"].GetKvlistValue().Values[0]
+	assert.Equal(t, "status", status.Key)
+	assert.Equal(t, int64(502), status.Value.GetIntValue())
+}
+
+// TestLogEntry_ToOTLPLogRecord_InvalidIDs 测试无法解析的 ID 保留为属性
+func TestLogEntry_ToOTLPLogRecord_InvalidIDs(t *testing.T) {
+	entry := &LogEntry{Level: "info", Trace: "not-a-trace-id", Span: "0000000000000000"}
+
+	record := entry.ToOTLPLogRecord()
+	assert.Empty(t, record.TraceId)
+	assert.Empty(t, record.SpanId)
+	assert.Zero(t, record.TimeUnixNano)
+	require.Len(t, record.Attributes, 2)
+	assert.Equal(t, "trace", record.Attributes[0].Key)
+	assert.Equal(t, "not-a-trace-id", record.Attributes[0].Value.GetStringValue())
+	assert.Equal(t, "span", record.Attributes[1].Key)
+}
@@ -5,8 +5,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 // LogEntry 对应我们设计的数据库表结构, 使用了 gorm 的约定来定义列属性和索引
@@ -42,8 +46,65 @@ type LogEntry struct {
 	Stack  *string `json:"stack,omitempty"`
 }
 
-// ParseLogFile 解析一个日志文件, 并将结果放入目标队列
-func ParseLogFile(filePath string, entriesChan chan<- *LogEntry) {
+// OpenTelemetry 日志数据模型中的 SeverityNumber 取值，每个区间取其第一个值
+const (
+	SeverityUnspecified = 0
+	SeverityTrace       = 1
+	SeverityDebug       = 5
+	SeverityInfo        = 9
+	SeverityWarn        = 13
+	SeverityError       = 17
+	SeverityFatal       = 21
+)
+
+// SeverityNumber 将 zerolog 的级别字符串映射为 OTel 的 SeverityNumber，
+// 以便将文件日志直接转换为 OTLP 日志记录。
+// zerolog 的 panic 级别同样映射为 FATAL；未知级别返回 UNSPECIFIED (0)。
+func (e *LogEntry) SeverityNumber() int {
+	switch strings.ToLower(e.Level) {
+	case "trace":
+		return SeverityTrace
+	case "debug":
+		return SeverityDebug
+	case "info":
+		return SeverityInfo
+	case "warn", "warning":
+		return SeverityWarn
+	case "error":
+		return SeverityError
+	case "fatal", "panic":
+		return SeverityFatal
+	default:
+		return SeverityUnspecified
+	}
+}
+
+// DefaultMaxLineSize 是单行日志的默认最大长度 (1MB)
+const DefaultMaxLineSize = 1024 * 1024
+
+// oversizedPrefixLen 是超长行告警中保留的行首长度
+const oversizedPrefixLen = 256
+
+// 默认的 trace/span ID 字段名
+const (
+	DefaultTraceKey = "trace"
+	DefaultSpanKey  = "span"
+)
+
+// ParseOptions 控制 ParseLogFile 的行为，零值即为默认配置
+type ParseOptions struct {
+	// MaxLineSize 限制单行的最大字节数，<= 0 时使用 DefaultMaxLineSize
+	MaxLineSize int
+	// TraceKey 和 SpanKey 是日志中 trace/span ID 的字段名，
+	// 应与 o11y.LogConfig 的 TraceIDKey/SpanIDKey 保持一致，为空时使用默认值
+	TraceKey string
+	SpanKey  string
+}
+
+// ParseLogFile 解析一个日志文件, 并将结果放入目标队列。
+// 超长的行（例如携带巨大堆栈的 panic 日志）会被跳过并输出一条带行首内容的结构化告警，
+// 而不会中断整个文件的解析。
+func ParseLogFile(filePath string, entriesChan chan<- *LogEntry, opts ParseOptions) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", filePath, err)
@@ -51,30 +112,74 @@ func ParseLogFile(filePath string, entriesChan chan<- *LogEntry) {
 	}
 	defer file.Close()
 
+	maxLineSize := opts.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+
 	// 为这个文件创建一个专属的解析器
 	parser := NewLogFileParser()
+	parser.SetIDKeys(opts.TraceKey, opts.SpanKey)
 
-	scanner := bufio.NewScanner(file)
-	// 增加 buffer size 防止超长行导致 panic
-	const maxCapacity = 1024 * 1024 // 1MB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	reader := bufio.NewReader(file)
+	var buf []byte
+	for {
+		line, size, err := readLine(reader, buf[:0], maxLineSize)
+		buf = line
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+		if size > maxLineSize {
+			log.Warn().
+				Str("file", filePath).
+				Int("size", size).
+				Int("max_line_size", maxLineSize).
+				Str("prefix", string(line[:min(len(line), oversizedPrefixLen)])).
+				Msg("Skipping log line exceeding the maximum line size")
+		} else if len(line) > 0 {
+			// 使用解析器对象的方法，而不是全局函数
+			entry, parseErr := parser.ParseLine(line)
+			if parseErr != nil {
+				// 只有在第一次检测失败时才会出错，后续基本不会
+				fmt.Fprintf(os.Stderr, "Error parsing line in %s: %v\n", filePath, parseErr)
+			} else {
+				entriesChan <- entry
+			}
 		}
 
-		// 使用解析器对象的方法，而不是全局函数
-		entry, err := parser.ParseLine(line)
 		if err != nil {
-			// 只有在第一次检测失败时才会出错，后续基本不会
-			fmt.Fprintf(os.Stderr, "Error parsing line in %s: %v\n", filePath, err)
-			continue
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filePath, err)
+			}
+			return
+		}
+	}
+}
+
+// readLine 从 r 读取一行（不含行尾的换行符），追加到 buf 后返回。
+// 超过 maxSize 的部分会被丢弃，size 返回该行的完整长度，调用方据此判断是否被截断。
+func readLine(r *bufio.Reader, buf []byte, maxSize int) (line []byte, size int, err error) {
+	line = buf
+	for {
+		var chunk []byte
+		chunk, err = r.ReadSlice('\n')
+		size += len(chunk)
+		if room := maxSize + 1 - len(line); room > 0 { // +1 为换行符预留
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+
+	// 去掉行尾的 "\n" 或 "\r\n"
+	if err == nil {
+		size--
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if bytes.HasSuffix(line, []byte("\r")) {
+			line = line[:len(line)-1]
+			size--
 		}
-		entriesChan <- entry
 	}
+	return line, size, err
 }
 
 // TimestampPrecision 是一个枚举类型，用于表示检测到的时间戳精度
@@ -88,32 +193,89 @@ const (
 	PrecisionNanos
 )
 
+// LineFormat 表示日志行的编码格式
+type LineFormat int
+
+const (
+	FormatAuto   LineFormat = iota // 根据第一行自动检测
+	FormatJSON                     // zerolog 输出的 JSON 行
+	FormatLogfmt                   // key=value key2="value 2"
+	FormatText                     // 无结构的纯文本
+)
+
 // LogFileParser 是一个有状态的解析器，用于处理单个日志文件
 type LogFileParser struct {
+	// 行格式，FormatAuto 表示尚未检测
+	format LineFormat
+
 	// 存储检测到的精度
 	precision TimestampPrecision
 
 	// 存储一个直接的转换函数指针，避免每次都 switch
 	tsParser func(tsInt int64) time.Time
+
+	// trace/span ID 的字段名
+	traceKey string
+	spanKey  string
 }
 
-// NewLogFileParser 创建一个新的解析器实例
+// NewLogFileParser 创建一个新的解析器实例，行格式在第一行时自动检测
 func NewLogFileParser() *LogFileParser {
+	return NewLogFileParserWithFormat(FormatAuto)
+}
+
+// NewLogFileParserWithFormat 创建一个使用指定行格式的解析器实例
+func NewLogFileParserWithFormat(format LineFormat) *LogFileParser {
 	return &LogFileParser{
+		format:    format,
 		precision: PrecisionUnknown,
 		tsParser:  nil, // 初始为空
+		traceKey:  DefaultTraceKey,
+		spanKey:   DefaultSpanKey,
 	}
 }
 
-// ParseLine 解析单行日志。它会在第一次调用时检测并设置精度。
+// SetIDKeys 设置 trace/span ID 的字段名，空字符串表示保留当前值
+func (p *LogFileParser) SetIDKeys(traceKey, spanKey string) {
+	if traceKey != "" {
+		p.traceKey = traceKey
+	}
+	if spanKey != "" {
+		p.spanKey = spanKey
+	}
+}
+
+// Format 返回解析器当前使用的行格式
+func (p *LogFileParser) Format() LineFormat {
+	return p.format
+}
+
+// ParseLine 解析单行日志。它会在第一次调用时检测并设置行格式和精度。
 func (p *LogFileParser) ParseLine(line []byte) (*LogEntry, error) {
+	// 如果格式未知，则根据第一行进行一次性检测
+	if p.format == FormatAuto {
+		p.format = detectLineFormat(line)
+	}
+
 	var rawLog map[string]any
 
-	// 使用 Decoder 并开启 UseNumber
-	decoder := json.NewDecoder(bytes.NewReader(line))
-	decoder.UseNumber()
-	if err := decoder.Decode(&rawLog); err != nil {
-		return nil, fmt.Errorf("failed to decode json: %w", err)
+	switch p.format {
+	case FormatText:
+		// 纯文本没有结构，整行作为消息
+		return &LogEntry{Message: string(line)}, nil
+	case FormatLogfmt:
+		var err error
+		rawLog, err = decodeLogfmt(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode logfmt: %w", err)
+		}
+	default:
+		// 使用 Decoder 并开启 UseNumber
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&rawLog); err != nil {
+			return nil, fmt.Errorf("failed to decode json: %w", err)
+		}
 	}
 
 	// 如果精度未知，则进行一次性检测
@@ -133,6 +295,18 @@ func (p *LogFileParser) ParseLine(line []byte) (*LogEntry, error) {
 	return entry, nil
 }
 
+// detectLineFormat 根据一行日志的内容猜测其格式
+func detectLineFormat(line []byte) LineFormat {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	if isLogfmt(trimmed) {
+		return FormatLogfmt
+	}
+	return FormatText
+}
+
 // detectAndSetPrecision 从原始日志中检测时间戳精度并设置解析器状态
 func (p *LogFileParser) detectAndSetPrecision(rawLog map[string]any) error {
 	tsValue, ok := rawLog["time"]
@@ -181,7 +355,12 @@ func (p *LogFileParser) mapToLogEntry(rawLog map[string]any) (*LogEntry, error)
 			continue
 		}
 
+		// 可配置的字段名放在最前面，优先于固定字段名匹配
 		switch key {
+		case p.traceKey:
+			entry.Trace, _ = value.(string)
+		case p.spanKey:
+			entry.Span, _ = value.(string)
 		case "environment":
 			entry.Environment, _ = value.(string)
 		case "version":
@@ -190,12 +369,8 @@ func (p *LogFileParser) mapToLogEntry(rawLog map[string]any) (*LogEntry, error)
 			entry.Service, _ = value.(string)
 		case "module":
 			entry.Module, _ = value.(string)
-		case "trace":
-			entry.Trace, _ = value.(string)
 		case "user":
 			entry.User, _ = value.(string)
-		case "span":
-			entry.Span, _ = value.(string)
 		case "level":
 			entry.Level, _ = value.(string)
 		case "message":
@@ -226,7 +401,7 @@ func (p *LogFileParser) mapToLogEntry(rawLog map[string]any) (*LogEntry, error)
 			entry.Timestamp = time
 		default:
 			// 所有未知的字段都放入 attributes JSON blob 中
-			entry.Attributes[key] = value
+			entry.Attributes[key] = normalizeNumbers(value)
 		}
 	}
 
@@ -237,3 +412,33 @@ func (p *LogFileParser) mapToLogEntry(rawLog map[string]any) (*LogEntry, error)
 
 	return entry, nil
 }
+
+// normalizeNumbers 将 UseNumber 解码得到的 json.Number（包括嵌套在对象和数组中的）
+// 转换为 int64 或 float64，以便 JSONB 查询和 GORM 序列化将其视为数字而不是字符串。
+// 不含小数点或指数的数字转换为 int64，超出 int64 范围时退化为 float64。
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = normalizeNumbers(elem)
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = normalizeNumbers(elem)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -206,6 +210,39 @@ func TestLogFileParser_ParseLine(t *testing.T) {
 	}
 }
 
+// TestLogFileParser_NumericAttributes 测试数值属性保持数字类型，而不是 json.Number 字符串
+func TestLogFileParser_NumericAttributes(t *testing.T) {
+	line := `{"time": 1763461800000, "level": "info", "message": "m", "count": 42, "ratio": 0.5, "big": 1e3, "huge": 92233720368547758070, "nested": {"ids": [1, 2.5]}}`
+
+	entry, err := NewLogFileParser().ParseLine([]byte(line))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), entry.Attributes["count"])
+	assert.Equal(t, 0.5, entry.Attributes["ratio"])
+	assert.Equal(t, float64(1000), entry.Attributes["big"])
+	assert.Equal(t, 92233720368547758070.0, entry.Attributes["huge"])
+	assert.Equal(t, map[string]any{"ids": []any{int64(1), 2.5}}, entry.Attributes["nested"])
+
+	// 序列化后整数仍是 JSON 数字，而不是字符串
+	encoded, err := json.Marshal(entry.Attributes)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"count":42`)
+	assert.NotContains(t, string(encoded), `"count":"42"`)
+}
+
+// TestLogFileParser_IDKeys 测试自定义 trace/span 字段名
+func TestLogFileParser_IDKeys(t *testing.T) {
+	line := `{"time": 1763461800000, "level": "info", "message": "m", "traceID": "t1", "spanID": "s1", "trace": "other"}`
+
+	parser := NewLogFileParser()
+	parser.SetIDKeys("traceID", "spanID")
+	entry, err := parser.ParseLine([]byte(line))
+	require.NoError(t, err)
+	assert.Equal(t, "t1", entry.Trace)
+	assert.Equal(t, "s1", entry.Span)
+	// 默认字段名不再特殊处理，作为普通属性保留
+	assert.Equal(t, "other", entry.Attributes["trace"])
+}
+
 // TestParseLogFile 是对文件级解析函数的集成测试
 func TestParseLogFile(t *testing.T) {
 	// 1. 准备一个临时日志文件
@@ -222,7 +259,7 @@ func TestParseLogFile(t *testing.T) {
 	entriesChan := make(chan *LogEntry, 5)
 
 	// 3. 执行解析
-	ParseLogFile(logFilePath, entriesChan)
+	ParseLogFile(logFilePath, entriesChan, ParseOptions{})
 	close(entriesChan) // 关闭 channel 以便我们可以遍历它
 
 	// 4. 断言结果
@@ -240,6 +277,141 @@ func TestParseLogFile(t *testing.T) {
 	assert.Equal(t, "file not found", *results[1].Error)
 }
 
+// TestParseLogFile_MaxLineSize 测试超长行被跳过并告警，而不会中断后续行的解析
+func TestParseLogFile_MaxLineSize(t *testing.T) {
+	longLine := `{"time": 1763461800000, "level": "panic", "message": "boom", "stack": "` + strings.Repeat("x", 200) + `"}`
+	logContent := `{"time": 1763461800000, "level": "info", "message": "before"}` + "\r\n" +
+		longLine + "\n" +
+		`{"time": 1763461801000, "level": "info", "message": "after"}`
+
+	logFilePath := filepath.Join(t.TempDir(), "oversized.log")
+	require.NoError(t, os.WriteFile(logFilePath, []byte(logContent), 0o644))
+
+	// 捕获 Agent 自身的告警日志
+	var logBuf bytes.Buffer
+	originalLogger := log.Logger
+	log.Logger = zerolog.New(&logBuf)
+	t.Cleanup(func() { log.Logger = originalLogger })
+
+	entriesChan := make(chan *LogEntry, 5)
+	ParseLogFile(logFilePath, entriesChan, ParseOptions{MaxLineSize: 80})
+	close(entriesChan)
+
+	var messages []string
+	for entry := range entriesChan {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"before", "after"}, messages)
+
+	var warning struct {
+		Level       string `json:"level"`
+		Size        int    `json:"size"`
+		MaxLineSize int    `json:"max_line_size"`
+		Prefix      string `json:"prefix"`
+	}
+	require.NoError(t, json.Unmarshal(logBuf.Bytes(), &warning))
+	assert.Equal(t, "warn", warning.Level)
+	assert.Equal(t, len(longLine), warning.Size)
+	assert.Equal(t, 80, warning.MaxLineSize)
+	assert.True(t, strings.HasPrefix(longLine, warning.Prefix))
+	assert.NotEmpty(t, warning.Prefix)
+}
+
+// TestLogFileParser_Logfmt 测试 logfmt 格式的解析，包括引号和转义字符
+func TestLogFileParser_Logfmt(t *testing.T) {
+	baseTime := time.Date(2025, 11, 18, 10, 30, 0, 0, time.UTC)
+	line := fmt.Sprintf(`time=%d level=info service=user-service message="say \"hi\" to C:\\tmp" trace=abc login_method=password retries=3 debug`,
+		baseTime.UnixMilli())
+
+	parser := NewLogFileParser()
+	entry, err := parser.ParseLine([]byte(line))
+	require.NoError(t, err)
+	assert.Equal(t, FormatLogfmt, parser.Format())
+
+	assert.True(t, baseTime.Equal(entry.Timestamp), "Timestamp mismatch")
+	assert.Equal(t, "info", entry.Level)
+	assert.Equal(t, "user-service", entry.Service)
+	assert.Equal(t, `say "hi" to C:\tmp`, entry.Message)
+	assert.Equal(t, "abc", entry.Trace)
+	assert.Equal(t, "password", entry.Attributes["login_method"])
+	assert.Equal(t, int64(3), entry.Attributes["retries"])
+	assert.Equal(t, true, entry.Attributes["debug"])
+
+	// 后续行沿用已检测的格式
+	entry, err = parser.ParseLine([]byte(fmt.Sprintf(`time=%d level=warn message="tab\there" empty=`, baseTime.UnixMilli())))
+	require.NoError(t, err)
+	assert.Equal(t, "tab\there", entry.Message)
+	assert.Equal(t, "", entry.Attributes["empty"])
+}
+
+// TestLogFileParser_LogfmtErrors 测试格式错误的 logfmt 行
+func TestLogFileParser_LogfmtErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+	}{
+		{"Unterminated_quote", `time=1763461800 message="unterminated`},
+		{"Invalid_escape", `time=1763461800 message="bad \q escape"`},
+		{"Missing_key", `time=1763461800 =value`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := NewLogFileParserWithFormat(FormatLogfmt)
+			_, err := parser.ParseLine([]byte(tc.line))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "failed to decode logfmt")
+		})
+	}
+}
+
+// TestLogFileParser_Text 测试纯文本行只填充 Message
+func TestLogFileParser_Text(t *testing.T) {
+	parser := NewLogFileParser()
+	entry, err := parser.ParseLine([]byte("2025/11/18 10:30:00 server started on :8080"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatText, parser.Format())
+	assert.Equal(t, &LogEntry{Message: "2025/11/18 10:30:00 server started on :8080"}, entry)
+
+	// 文本格式确定后，即使出现 JSON 也按文本处理
+	entry, err = parser.ParseLine([]byte(`{"time": 1763461800}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"time": 1763461800}`, entry.Message)
+}
+
+// TestDetectLineFormat 测试第一行的格式检测
+func TestDetectLineFormat(t *testing.T) {
+	assert.Equal(t, FormatJSON, detectLineFormat([]byte(`  {"time": 1763461800}`)))
+	assert.Equal(t, FormatLogfmt, detectLineFormat([]byte(`time=1763461800 level=info`)))
+	assert.Equal(t, FormatText, detectLineFormat([]byte(`hello world`)))
+	assert.Equal(t, FormatText, detectLineFormat([]byte(`level=info but then prose`)))
+	assert.Equal(t, FormatText, detectLineFormat([]byte(`say "a=b"`)))
+}
+
+// TestLogEntry_SeverityNumber 测试 zerolog 级别到 OTel SeverityNumber 的映射
+func TestLogEntry_SeverityNumber(t *testing.T) {
+	testCases := []struct {
+		level    string
+		expected int
+	}{
+		{"trace", SeverityTrace},
+		{"debug", SeverityDebug},
+		{"info", SeverityInfo},
+		{"warn", SeverityWarn},
+		{"error", SeverityError},
+		{"fatal", SeverityFatal},
+		{"panic", SeverityFatal},
+		{"INFO", SeverityInfo},
+		{"", SeverityUnspecified},
+		{"verbose", SeverityUnspecified},
+	}
+
+	for _, tc := range testCases {
+		entry := &LogEntry{Level: tc.level}
+		assert.Equal(t, tc.expected, entry.SeverityNumber(), "level %q", tc.level)
+	}
+}
+
 // createLogLine 是一个辅助函数，用于将 map 转换为 JSON 字节切片
 func createLogLine(data map[string]interface{}) []byte {
 	bytes, err := json.Marshal(data)
@@ -13,23 +13,18 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
-	"gopkg.in/yaml.v3"
 
 	"github.com/oy3o/o11y"
 )
 
-// AppConfig is a wrapper struct to match the YAML structure.
-type AppConfig struct {
-	O11y o11y.Config `yaml:"o11y"`
-}
-
 // Global variable to hold our instrumented HTTP client.
 var instrumentedClient *http.Client
 
 func main() {
 	// --- 1. Load Configuration ---
 	// In a real app, you might use flags or env vars to find the config file.
-	cfg, err := loadConfig("example/config.yaml")
+	// o11y.LoadConfigFile understands the config nested under the "o11y" key.
+	cfg, err := o11y.LoadConfigFile("example/config.yaml")
 	if err != nil {
 		// Using fmt here because our logger isn't configured yet.
 		fmt.Printf("fatal: failed to load config: %v\n", err)
@@ -38,7 +33,7 @@ func main() {
 
 	// --- 2. Initialize o11y Library ---
 	// This single line sets up logging, tracing, and metrics!
-	shutdown, err := o11y.Init(cfg.O11y)
+	shutdown, err := o11y.Init(cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize o11y")
 	}
@@ -57,7 +52,7 @@ func main() {
 
 	// Wrap the entire mux with the o11y middleware. This automatically
 	// handles tracing, panic recovery, and logger injection for all routes.
-	o11yMiddleware := o11y.Handler(cfg.O11y)
+	o11yMiddleware := o11y.Handler(cfg)
 	wrappedMux := o11yMiddleware(mux)
 
 	server := &http.Server{
@@ -172,18 +167,3 @@ func fetchUserData(ctx context.Context, userID string) (string, error) {
 
 	return userData, err
 }
-
-// loadConfig reads a YAML file and unmarshals it into the AppConfig struct.
-func loadConfig(path string) (*AppConfig, error) {
-	f, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var cfg AppConfig
-	err = yaml.Unmarshal(f, &cfg)
-	if err != nil {
-		return nil, err
-	}
-	return &cfg, nil
-}
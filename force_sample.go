@@ -0,0 +1,131 @@
+package o11y
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	tc "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// forceSampleKey is the context key marking spans started beneath it to be sampled.
+type forceSampleKey struct{}
+
+// withForceSample returns a Context whose new root spans are always sampled by forceSampler.
+func withForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleKey{}, true)
+}
+
+// isForceSampled reports whether the Context was marked by withForceSample.
+func isForceSampled(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceSampleKey{}).(bool)
+	return forced
+}
+
+// forceSampler samples every span started with a force-sampled Context
+// and delegates the decision to base otherwise.
+type forceSampler struct {
+	base tc.Sampler
+}
+
+// ShouldSample implements tc.Sampler.
+func (s forceSampler) ShouldSample(p tc.SamplingParameters) tc.SamplingResult {
+	if isForceSampled(p.ParentContext) {
+		return tc.SamplingResult{
+			Decision:   tc.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+// Description implements tc.Sampler.
+func (s forceSampler) Description() string {
+	return "ForceSampler{" + s.base.Description() + "}"
+}
+
+// ForceSampleMiddleware marks requests carrying the TraceConfig.ForceSampleHeader from a trusted
+// source, so their traces are sampled regardless of the sampling ratio.
+// It must run before the span is started, i.e. outside otelhttp; Handler already includes it.
+// If the header or its guards are not configured, the returned middleware is a no-op.
+func ForceSampleMiddleware(cfg Config) func(http.Handler) http.Handler {
+	trusted := newForceSampleGuard(cfg.Trace)
+	if trusted == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trusted(r) {
+				r = r.WithContext(withForceSample(r.Context()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newForceSampleGuard returns a predicate reporting whether a request may force sampling,
+// or nil if forced sampling is not enabled.
+func newForceSampleGuard(cfg TraceConfig) func(r *http.Request) bool {
+	if cfg.ForceSampleHeader == "" {
+		return nil
+	}
+	if cfg.ForceSampleSecret == "" && len(cfg.ForceSampleTrustedNetworks) == 0 {
+		log.Warn().Str("header", cfg.ForceSampleHeader).
+			Msg("Force-sample header is configured without a secret or trusted networks, ignoring it.")
+		return nil
+	}
+
+	var networks []netip.Prefix
+	for _, cidr := range cfg.ForceSampleTrustedNetworks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			log.Error().Err(err).Str("cidr", cidr).Msg("Invalid force-sample trusted network, skipping it.")
+			continue
+		}
+		networks = append(networks, prefix)
+	}
+	if cfg.ForceSampleSecret == "" && len(networks) == 0 {
+		// All networks were invalid: fail closed rather than trusting everyone.
+		return nil
+	}
+
+	return func(r *http.Request) bool {
+		value := r.Header.Get(cfg.ForceSampleHeader)
+		if value == "" {
+			return false
+		}
+
+		if cfg.ForceSampleSecret != "" {
+			if subtle.ConstantTimeCompare([]byte(value), []byte(cfg.ForceSampleSecret)) != 1 {
+				return false
+			}
+		} else if forced, _ := strconv.ParseBool(value); !forced {
+			return false
+		}
+
+		if len(networks) == 0 {
+			return true
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, prefix := range networks {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,68 @@
+package o11y
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestForceSampleGuard(t *testing.T) {
+	newRequest := func(remoteAddr, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if value != "" {
+			r.Header.Set("X-O11y-Force-Sample", value)
+		}
+		return r
+	}
+
+	// Not configured, or configured without guards: disabled.
+	assert.Nil(t, newForceSampleGuard(TraceConfig{}))
+	assert.Nil(t, newForceSampleGuard(TraceConfig{ForceSampleHeader: "X-O11y-Force-Sample"}))
+
+	secret := newForceSampleGuard(TraceConfig{ForceSampleHeader: "X-O11y-Force-Sample", ForceSampleSecret: "s3cret"})
+	assert.True(t, secret(newRequest("203.0.113.1:1234", "s3cret")))
+	assert.False(t, secret(newRequest("203.0.113.1:1234", "1")))
+	assert.False(t, secret(newRequest("203.0.113.1:1234", "")))
+
+	network := newForceSampleGuard(TraceConfig{
+		ForceSampleHeader:          "X-O11y-Force-Sample",
+		ForceSampleTrustedNetworks: []string{"10.0.0.0/8", "not-a-cidr"},
+	})
+	assert.True(t, network(newRequest("10.1.2.3:1234", "1")))
+	assert.True(t, network(newRequest("[::ffff:10.1.2.3]:1234", "true")))
+	assert.False(t, network(newRequest("10.1.2.3:1234", "0")))
+	assert.False(t, network(newRequest("203.0.113.1:1234", "1")))
+}
+
+func TestHandler_ForceSample(t *testing.T) {
+	traceCfg := TraceConfig{
+		Enabled:           true,
+		Exporter:          "none",
+		SampleRatio:       0, // Never sample unless forced.
+		ForceSampleHeader: "X-O11y-Force-Sample",
+		ForceSampleSecret: "s3cret",
+	}
+	_, shutdown, err := setupTracing(traceCfg, resource.Default())
+	assert.NoError(t, err)
+	defer shutdown(context.Background())
+
+	var sampled bool
+	handler := Handler(Config{Service: "test-service", Trace: traceCfg})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sampled = trace.SpanContextFromContext(r.Context()).IsSampled()
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.False(t, sampled)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-O11y-Force-Sample", "s3cret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, sampled)
+}
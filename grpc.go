@@ -2,6 +2,7 @@ package o11y
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"google.golang.org/grpc"
 	gcodes "google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 // GRPCServerOptions 返回一组推荐的 gRPC ServerOption。
@@ -20,22 +23,26 @@ import (
 // 1. OpenTelemetry StatsHandler (处理 Tracing 和 Metrics)
 // 2. Unary & Stream Interceptors (处理 Logger 注入、Panic 恢复和访问日志)
 //
+// 可通过 GRPCOption 定制拦截器，例如 WithGRPCStatusDetails。
+//
 // 用法:
 //
 //	s := grpc.NewServer(o11y.GRPCServerOptions()...)
-func GRPCServerOptions() []grpc.ServerOption {
+func GRPCServerOptions(opts ...GRPCOption) []grpc.ServerOption {
 	return []grpc.ServerOption{
 		// 1. OTel 官方集成：负责 Context 传播、Span 创建和标准 RPC 指标
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
 
 		// 2. 自定义拦截器链
-		grpc.ChainUnaryInterceptor(unaryServerInterceptor()),
+		grpc.ChainUnaryInterceptor(unaryServerInterceptor(opts...)),
 		grpc.ChainStreamInterceptor(streamServerInterceptor()),
 	}
 }
 
 // unaryServerInterceptor 处理单次调用 (Request-Response)
-func unaryServerInterceptor() grpc.UnaryServerInterceptor {
+func unaryServerInterceptor(opts ...GRPCOption) grpc.UnaryServerInterceptor {
+	o := newGRPCOptions(opts)
+
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 		// 1. 准备 Logger 和 Context
 		// otelgrpc 已经运行，Context 中已有 Span
@@ -75,9 +82,25 @@ func unaryServerInterceptor() grpc.UnaryServerInterceptor {
 		// 只有错误发生时才打印 Error 日志，正常请求可根据 Level 决定是否打印 Info
 		duration := time.Since(startTime)
 		if err != nil {
-			// 忽略客户端取消导致的错误日志，避免刷屏
-			if status.Code(err) != gcodes.Canceled {
-				logger.Error().Err(err).Dur("dur", duration).Msg("gRPC execution failed")
+			switch status.Code(err) {
+			case gcodes.Canceled:
+				// 忽略客户端取消导致的错误日志，避免刷屏
+			case gcodes.DeadlineExceeded:
+				// 超时通常是客户端给的时间不够，记录可用时间以便对比
+				e := logger.Warn().Err(err).Dur("dur", duration)
+				if deadline, ok := ctx.Deadline(); ok {
+					e = e.Dur("available", deadline.Sub(startTime))
+				}
+				e.Msg("gRPC deadline exceeded")
+			default:
+				st := status.Convert(err)
+				e := logger.Error().Err(err).Str("grpc_code", st.Code().String()).Dur("dur", duration)
+				if o.statusDetails {
+					if details := statusDetailsJSON(st); details != nil {
+						e = e.RawJSON("grpc_details", details)
+					}
+				}
+				e.Msg("gRPC execution failed")
 			}
 		} else {
 			logger.Debug().Dur("dur", duration).Msg("gRPC execution success")
@@ -123,25 +146,71 @@ func streamServerInterceptor() grpc.StreamServerInterceptor {
 }
 
 // injectLogger 辅助函数：将 TraceID 注入 Logger 并放入 Context
+// 如果客户端设置了截止时间，剩余时间会以 rpc.deadline_ms 记录到日志字段和 Span 属性中
 func injectLogger(ctx context.Context, method string) context.Context {
 	span := trace.SpanFromContext(ctx)
-	parentLogger := GetLoggerFromContext(ctx)
+	// 即使没有 Trace，也注入 method 字段方便检索
+	c := uncorrelatedLogger(ctx).With().Str("rpc_method", method)
 
-	// 如果有 Trace，注入 trace_id 和 span_id
-	if span.SpanContext().IsValid() {
-		l := parentLogger.With().
-			Str("trace_id", span.SpanContext().TraceID().String()).
-			Str("span_id", span.SpanContext().SpanID().String()).
-			Str("rpc_method", method).
-			Logger()
-		return l.WithContext(ctx)
+	// 记录客户端给出的时间预算，便于排查客户端与服务端超时设置不一致的问题
+	if deadline, ok := ctx.Deadline(); ok {
+		budget := time.Until(deadline).Milliseconds()
+		c = c.Int64("rpc.deadline_ms", budget)
+		span.SetAttributes(attribute.Int64("rpc.deadline_ms", budget))
 	}
 
-	// 即使没有 Trace，也注入 method 字段方便检索
-	l := parentLogger.With().Str("rpc_method", method).Logger()
+	ctx = context.WithValue(ctx, rpcMethodKey{}, method)
+	// 如果有 Trace，注入 trace_id 和 span_id（字段名可通过 LogConfig 配置）
+	if span.SpanContext().IsValid() {
+		ctx, _ = contextWithSpanLogger(ctx, c.Logger(), span.SpanContext(), "")
+		return ctx
+	}
+	l := c.Logger()
 	return l.WithContext(ctx)
 }
 
+// rpcMethodKey 是 injectLogger 保存 gRPC 方法名的 Context key
+type rpcMethodKey struct{}
+
+// RPCMethodFromContext 返回 gRPC 拦截器保存在 Context 中的完整方法名（例如 "/pkg.Service/Method"），
+// 以便在 Handler 内的业务指标中关联来源 RPC。
+//
+// 用法:
+//
+//	if method, ok := o11y.RPCMethodFromContext(ctx); ok {
+//	    opts = append(opts, o11y.WithMetricAttributes(attribute.String("rpc.method", method)))
+//	}
+func RPCMethodFromContext(ctx context.Context) (string, bool) {
+	method, ok := ctx.Value(rpcMethodKey{}).(string)
+	return method, ok
+}
+
+// statusDetailsJSON 将 status 的 details 以 protojson 格式序列化为 JSON 数组，没有 details 时返回 nil。
+// 无法解析的 detail（例如类型未注册）以错误信息字符串代替，避免丢失整条日志。
+func statusDetailsJSON(st *status.Status) []byte {
+	details := st.Details()
+	if len(details) == 0 {
+		return nil
+	}
+
+	out := make([]json.RawMessage, 0, len(details))
+	for _, d := range details {
+		var raw []byte
+		var err error
+		if msg, ok := d.(proto.Message); ok {
+			raw, err = protojson.Marshal(msg)
+		} else {
+			err = fmt.Errorf("%v", d)
+		}
+		if err != nil {
+			raw, _ = json.Marshal(err.Error())
+		}
+		out = append(out, raw)
+	}
+	b, _ := json.Marshal(out)
+	return b
+}
+
 // wrappedServerStream 用于在 Stream 拦截器中传递修改后的 Context
 type wrappedServerStream struct {
 	grpc.ServerStream
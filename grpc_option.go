@@ -0,0 +1,28 @@
+package o11y
+
+// GRPCOption defines a function that customizes the gRPC interceptors created by GRPCServerOptions.
+type GRPCOption func(*grpcOptions)
+
+// grpcOptions holds the settings collected from GRPCOptions.
+type grpcOptions struct {
+	// statusDetails logs the details of failed calls' gRPC status.
+	statusDetails bool
+}
+
+// newGRPCOptions applies the given options on top of the defaults.
+func newGRPCOptions(opts []GRPCOption) grpcOptions {
+	var o grpcOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithGRPCStatusDetails adds the details of the gRPC status returned by a failed handler,
+// such as errdetails.BadRequest field violations, to the error log as the "grpc_details"
+// field, a JSON array in protojson format. Details may contain user input, so this is opt-in.
+func WithGRPCStatusDetails() GRPCOption {
+	return func(o *grpcOptions) {
+		o.statusDetails = true
+	}
+}
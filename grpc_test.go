@@ -1,10 +1,18 @@
 package o11y
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -80,6 +88,100 @@ func TestStreamServerInterceptor_Panic(t *testing.T) {
 	assert.Equal(t, codes.Internal, st.Code())
 }
 
+// TestUnaryServerInterceptor_Deadline verifies the client deadline is logged and recorded on the span
+func TestUnaryServerInterceptor_Deadline(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+	ctx, span := Tracer.Start(ctx, "rpc")
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	interceptor := unaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetLoggerFromContext(ctx).Info().Msg("handling")
+		return nil, status.Error(codes.DeadlineExceeded, "too slow")
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test/Method"}
+
+	_, err := interceptor(ctx, "req", info, handler)
+	span.End()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	var entry struct {
+		Level      string  `json:"level"`
+		DeadlineMS int64   `json:"rpc.deadline_ms"`
+		Available  float64 `json:"available"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.InDelta(t, 2000, entry.DeadlineMS, 100)
+
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "warn", entry.Level)
+	assert.InDelta(t, 2000, entry.Available, 100)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, attributeKeys(spans[0].Attributes()), attribute.Key("rpc.deadline_ms"))
+}
+
+// TestUnaryServerInterceptor_StatusDetails verifies the status code and details are logged
+func TestUnaryServerInterceptor_StatusDetails(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "bad request").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "email", Description: "invalid format"}},
+	})
+	assert.NoError(t, err)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, st.Err()
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test/Method"}
+
+	call := func(opts ...GRPCOption) map[string]any {
+		var buf bytes.Buffer
+		ctx := zerolog.New(&buf).WithContext(context.Background())
+		_, _ = unaryServerInterceptor(opts...)(ctx, "req", info, handler)
+
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry
+	}
+
+	// Details are opt-in, the code is always logged.
+	entry := call()
+	assert.Equal(t, "InvalidArgument", entry["grpc_code"])
+	assert.NotContains(t, entry, "grpc_details")
+
+	entry = call(WithGRPCStatusDetails())
+	details, ok := entry["grpc_details"].([]any)
+	assert.True(t, ok)
+	assert.Len(t, details, 1)
+	assert.Contains(t, fmt.Sprint(details[0]), "invalid format")
+}
+
+// TestRPCMethodFromContext verifies the interceptors expose the method to handlers
+func TestRPCMethodFromContext(t *testing.T) {
+	_, ok := RPCMethodFromContext(context.Background())
+	assert.False(t, ok)
+
+	var method string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		method, _ = RPCMethodFromContext(ctx)
+		return nil, nil
+	}
+	_, _ = unaryServerInterceptor()(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}, handler)
+	assert.Equal(t, "/test/Unary", method)
+
+	stream := &mockServerStream{ctx: context.Background()}
+	streamHandler := func(srv any, ss grpc.ServerStream) error {
+		method, _ = RPCMethodFromContext(ss.Context())
+		return nil
+	}
+	_ = streamServerInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, streamHandler)
+	assert.Equal(t, "/test/Stream", method)
+}
+
 type mockServerStream struct {
 	grpc.ServerStream
 	ctx context.Context
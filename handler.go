@@ -1,12 +1,15 @@
 package o11y
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/felixge/httpsnoop"
-	"github.com/rs/zerolog"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -25,75 +28,178 @@ import (
 //	    Addr:    ":8080",
 //	    Handler: o11yMiddleware(mux),
 //	}
-func Handler(cfg Config) func(http.Handler) http.Handler {
+//
+// Handler is the composition of MetricsMiddleware, LoggerMiddleware and RecoveryMiddleware,
+// wrapped with otelhttp to generate spans, itself wrapped with ForceSampleMiddleware. Use the individual middlewares when only some
+// of the concerns are needed, e.g. panic recovery and logging for an untraced admin endpoint.
+func Handler(cfg Config, opts ...HandlerOption) func(http.Handler) http.Handler {
+	metrics := MetricsMiddleware(cfg, opts...)
+	logger := LoggerMiddleware(cfg)
+	recovery := RecoveryMiddleware(cfg)
+	forceSample := ForceSampleMiddleware(cfg)
+
 	return func(next http.Handler) http.Handler {
-		// The inner handler contains our custom logic: panic recovery, metrics, and logger injection.
-		innerHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Record active requests
-			AddToInt64UpDownCounter(r.Context(), "http.server.active_requests", 1)
-			defer AddToInt64UpDownCounter(r.Context(), "http.server.active_requests", -1)
-
-			// 1. Contextual Logger Injection
-			// We do this *before* metrics capture so the handler has the logger.
-			span := trace.SpanFromContext(r.Context())
-			parentLogger := GetLoggerFromContext(r.Context())
-
-			var loggerWithTrace zerolog.Logger
-			if span.SpanContext().IsValid() {
-				loggerWithTrace = parentLogger.With().
-					Str("trace_id", span.SpanContext().TraceID().String()).
-					Str("span_id", span.SpanContext().SpanID().String()).
-					Logger()
-			} else {
-				loggerWithTrace = *parentLogger
-			}
+		// The inner handler contains our custom logic: metrics, logger injection and panic recovery.
+		// Recovery sits innermost so the 500 it writes is captured by the metrics middleware,
+		// and the logger is injected before it so panic logs carry the trace context.
+		innerHandler := metrics(logger(recovery(next)))
+
+		// Wrap with standard otelhttp to generate spans.
+		// Forced sampling must be decided before otelhttp starts the span.
+		return forceSample(otelhttp.NewHandler(innerHandler, cfg.Service))
+	}
+}
 
-			ctxWithLogger := loggerWithTrace.WithContext(r.Context())
-			reqWithLogger := r.WithContext(ctxWithLogger)
+// MetricsMiddleware records the standard HTTP server metrics:
+// http.server.active_requests, http.server.request.total and http.server.request.duration.
+// When the wrapped handler is an http.ServeMux, the matched pattern is used as http.route.
+// To capture the status code of recovered panics, place it outside RecoveryMiddleware.
+func MetricsMiddleware(cfg Config, opts ...HandlerOption) func(http.Handler) http.Handler {
+	o := newHandlerOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Record active requests.
+			// UpDownCounters keep a series alive per attribute set, so the route is only
+			// added when routes are normalized and cardinality is bounded.
+			var activeAttrs []attribute.KeyValue
+			if o.routeBounded() {
+				activeAttrs = append(activeAttrs, attribute.String("http.route", o.activeRoute(r)))
+			}
+			var hostAttr attribute.KeyValue
+			if o.hosts != nil {
+				hostAttr = attribute.String("server.address", o.serverAddress(r))
+				activeAttrs = append(activeAttrs, hostAttr)
+				trace.SpanFromContext(r.Context()).SetAttributes(hostAttr)
+			}
+			AddToInt64UpDownCounter(r.Context(), "http.server.active_requests", 1, activeAttrs...)
+			defer AddToInt64UpDownCounter(r.Context(), "http.server.active_requests", -1, activeAttrs...)
 
-			// 2. Metrics & Panic Recovery via httpsnoop
 			// httpsnoop.CaptureMetrics executes the handler and captures status code & duration.
 			// It automatically supports http.Flusher, http.Hijacker, etc.
-			m := httpsnoop.CaptureMetrics(http.HandlerFunc(func(ww http.ResponseWriter, rr *http.Request) {
-				defer func() {
-					if rcv := recover(); rcv != nil {
-						err := fmt.Errorf("panic recovered: %v", rcv)
-
-						// Record panic on Span
-						span.RecordError(err, trace.WithStackTrace(true))
-						span.SetStatus(codes.Error, "panic")
-
-						// Log panic
-						stack := FilterStackTrace(string(debug.Stack()), cfg.Log.StackFilters)
-						GetLoggerFromContext(rr.Context()).Error().
-							Interface("error", rcv).
-							Str("stack", stack).
-							Msg("HTTP request recovered from panic")
-
-						// Write 500 error. This updates the httpsnoop writer state.
-						ww.WriteHeader(http.StatusInternalServerError)
-						ww.Header().Set("Content-Type", "application/json; charset=utf-8")
-						fmt.Fprintf(ww, `{"code":"INTERNAL_ERROR","message":"Internal Server Error","trace_id":"%s"}`, ww.Header().Get("X-Trace-ID"))
-					}
-				}()
-
-				next.ServeHTTP(ww, rr)
-			}), w, reqWithLogger)
-
-			// 3. Record Metrics
-			route := r.URL.Path
+			holder := &routeHolder{}
+			r = r.WithContext(context.WithValue(r.Context(), routeKey{}, holder))
+			m := httpsnoop.CaptureMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				serveRouted(next, w, r)
+			}), w, r)
+			// The router records the matched route on the request it received.
+			routed := holder.request(r)
+
+			// Record Metrics
+			route := o.route(routed)
 			commonAttrs := []attribute.KeyValue{
 				attribute.String("http.method", r.Method),
 				attribute.String("http.route", route),
 				attribute.Int("http.status_code", m.Code),
+				attribute.String("http.status_class", statusClass(m.Code)),
+			}
+			if o.hosts != nil {
+				commonAttrs = append(commonAttrs, hostAttr)
+			}
+
+			counterAttrs := commonAttrs
+			if o.contentTypes != nil {
+				// The handler has written its headers by now.
+				// Clip so the histogram attributes below are not affected.
+				counterAttrs = append(slices.Clip(commonAttrs),
+					attribute.String("http.response.content_type", o.contentType(w.Header())))
+			}
+
+			// Path parameters are high-cardinality, so they only go on the span.
+			if span := trace.SpanFromContext(r.Context()); o.routeParams != nil && span.IsRecording() {
+				span.SetAttributes(o.routeParamAttributes(routed)...)
 			}
 
-			AddToIntCounter(r.Context(), "http.server.request.total", 1, commonAttrs...)
+			AddToIntCounter(r.Context(), "http.server.request.total", 1, counterAttrs...)
 			// m.Duration is time.Duration
 			RecordInFloat64Histogram(r.Context(), "http.server.request.duration", m.Duration.Seconds(), commonAttrs...)
 		})
+	}
+}
+
+// routeKey is the context key of the routeHolder of MetricsMiddleware.
+type routeKey struct{}
+
+// routeHolder receives the request passed to the router, on which http.ServeMux records the
+// matched pattern and path values. Handlers must not modify the request they receive, and
+// middlewares in between may copy it, so the request is handed back through the context.
+type routeHolder struct {
+	r atomic.Pointer[http.Request]
+}
+
+// request returns the request passed to the router, or r if none was recorded.
+func (h *routeHolder) request(r *http.Request) *http.Request {
+	if routed := h.r.Load(); routed != nil {
+		return routed
+	}
+	return r
+}
+
+// serveRouted calls next and records r in the routeHolder of its context, if any. The first
+// call to return, that of the innermost middleware, wins, since it is the closest to the router.
+func serveRouted(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if h, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		// Deferred so the route is known for panics recovered by outer middlewares.
+		defer h.r.CompareAndSwap(nil, r)
+	}
+	next.ServeHTTP(w, r)
+}
+
+// statusClass groups an HTTP status code into its class, e.g. 404 -> "4xx".
+// Grouping by class keeps RED dashboards simple without relying on the exact code.
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// LoggerMiddleware injects a logger enriched with the trace_id and span_id of the
+// current span into the request context, retrievable with GetLoggerFromContext.
+// Without an active span (e.g. when used without otelhttp) the parent logger is injected as is.
+func LoggerMiddleware(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctxWithLogger := r.Context()
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				ctxWithLogger, _ = contextWithSpanLogger(r.Context(), uncorrelatedLogger(r.Context()), sc, "")
+			}
+			serveRouted(next, w, r.WithContext(ctxWithLogger))
+		})
+	}
+}
+
+// RecoveryMiddleware recovers panics from the next handler, records them on the current span,
+// logs them with a filtered stack trace, and responds with a 500 JSON error.
+func RecoveryMiddleware(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rcv := recover(); rcv != nil {
+					err := fmt.Errorf("panic recovered: %v", rcv)
+
+					// Record panic on Span
+					span := trace.SpanFromContext(r.Context())
+					span.RecordError(err, trace.WithStackTrace(true))
+					span.SetStatus(codes.Error, "panic")
+
+					// Log panic
+					stack := FilterStackTrace(string(debug.Stack()), cfg.Log.stackFilters(), cfg.Log.stackFilterOptions()...)
+					// The trace IDs are added here as well, in case LoggerMiddleware is not in the chain.
+					logger := correlatedLogger(r.Context())
+					logger.Error().
+						Interface("error", rcv).
+						Str("stack", stack).
+						Msg("HTTP request recovered from panic")
 
-		// Wrap with standard otelhttp to generate spans
-		return otelhttp.NewHandler(innerHandler, cfg.Service)
+					// Write 500 error. This updates the httpsnoop writer state.
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					fmt.Fprintf(w, `{"code":"INTERNAL_ERROR","message":"Internal Server Error","trace_id":"%s"}`, w.Header().Get("X-Trace-ID"))
+				}
+			}()
+
+			serveRouted(next, w, r)
+		})
 	}
 }
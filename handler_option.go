@@ -0,0 +1,202 @@
+package o11y
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// HandlerOption defines a function that customizes the HTTP middlewares created by
+// Handler and MetricsMiddleware.
+type HandlerOption func(*handlerOptions)
+
+// handlerOptions holds the settings collected from HandlerOptions.
+type handlerOptions struct {
+	// routeNormalizer maps a request to a low-cardinality route template.
+	routeNormalizer func(r *http.Request) string
+
+	// contentTypes is the allow-list of response media types recorded on the request counter.
+	// Nil disables the attribute.
+	contentTypes map[string]struct{}
+
+	// hosts is the allow-list of request hosts recorded as server.address. Nil disables the attribute.
+	hosts map[string]struct{}
+
+	// routeParams extracts the path parameters of a request, recorded on the span only.
+	routeParams func(r *http.Request) map[string]string
+}
+
+// newHandlerOptions applies the given options on top of the defaults.
+func newHandlerOptions(opts []HandlerOption) handlerOptions {
+	var o handlerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRouteNormalizer sets a function mapping a request to its route template
+// (e.g. "/users/{id}" instead of "/users/42"), used as the http.route metric attribute.
+// Returning an empty string falls back to the default route: the pattern matched by
+// http.ServeMux (see patternRoute), or the raw URL path for other routers.
+//
+// Since normalized routes keep cardinality bounded, setting a normalizer also adds the
+// http.route attribute to the http.server.active_requests gauge. The normalizer is called
+// before the wrapped handler runs for that gauge, and after it for the request counter and
+// duration, so router-populated state is only visible to the latter. The gauge has no
+// fallback: an empty string is recorded as "unmatched", never as the raw URL path.
+//
+// Routers keeping their state on a request copy, such as chi, which stores its RouteContext
+// in the context of the request it passes on, must run the middleware inside the router so
+// the state is in the request context. The pattern is complete once the handler ran:
+//
+//	router := chi.NewRouter()
+//	router.Use(o11y.Handler(cfg, o11y.WithRouteNormalizer(func(r *http.Request) string {
+//	    return chi.RouteContext(r.Context()).RoutePattern()
+//	})))
+func WithRouteNormalizer(fn func(r *http.Request) string) HandlerOption {
+	return func(o *handlerOptions) {
+		o.routeNormalizer = fn
+	}
+}
+
+// WithContentTypes records the media type of the response Content-Type header, without
+// parameters (e.g. "application/json" for "application/json; charset=utf-8"), as the
+// http.response.content_type attribute of http.server.request.total.
+// To bound cardinality, only the given media types are recorded as is; any other value is
+// recorded as "other", and a missing header as "none".
+//
+// Example:
+//
+//	o11y.Handler(cfg, o11y.WithContentTypes("application/json", "application/x-protobuf", "text/html"))
+func WithContentTypes(allowed ...string) HandlerOption {
+	return func(o *handlerOptions) {
+		if o.contentTypes == nil {
+			o.contentTypes = make(map[string]struct{}, len(allowed))
+		}
+		for _, ct := range allowed {
+			o.contentTypes[mediaType(ct)] = struct{}{}
+		}
+	}
+}
+
+// contentType returns the http.response.content_type attribute value for a response header.
+func (o handlerOptions) contentType(header http.Header) string {
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		return "none"
+	}
+	ct = mediaType(ct)
+	if _, ok := o.contentTypes[ct]; !ok {
+		return "other"
+	}
+	return ct
+}
+
+// mediaType strips the parameters from a Content-Type value and normalizes its case.
+func mediaType(contentType string) string {
+	ct, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// WithHosts records the request host, lowercased and without port, as the server.address
+// attribute of the HTTP server metrics and the request span, for per-tenant observability
+// when one server serves several hosts. Since the Host header is client-controlled, only
+// the given hosts are recorded as is; any other host is recorded as "other".
+//
+// Example:
+//
+//	o11y.Handler(cfg, o11y.WithHosts("api.example.com", "admin.example.com"))
+func WithHosts(allowed ...string) HandlerOption {
+	return func(o *handlerOptions) {
+		if o.hosts == nil {
+			o.hosts = make(map[string]struct{}, len(allowed))
+		}
+		for _, host := range allowed {
+			o.hosts[normalizeHost(host)] = struct{}{}
+		}
+	}
+}
+
+// serverAddress returns the server.address attribute value for the request.
+func (o handlerOptions) serverAddress(r *http.Request) string {
+	host := normalizeHost(r.Host)
+	if _, ok := o.hosts[host]; !ok {
+		return "other"
+	}
+	return host
+}
+
+// normalizeHost strips the port and the trailing dot from a host and lowercases it.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// WithRouteParams sets a function returning the path parameters of a request
+// (e.g. {"user_id": "123"} for "/users/{user_id}"), recorded on the request span as
+// "http.route.param.<name>" attributes for debugging. They are never added to metrics,
+// whose attributes must stay low-cardinality. The function is called after the wrapped
+// handler ran, so router-populated state is visible, and only if the span is recording.
+//
+// Example:
+//
+//	o11y.Handler(cfg, o11y.WithRouteParams(func(r *http.Request) map[string]string {
+//	    return map[string]string{"user_id": r.PathValue("user_id")}
+//	}))
+func WithRouteParams(fn func(r *http.Request) map[string]string) HandlerOption {
+	return func(o *handlerOptions) {
+		o.routeParams = fn
+	}
+}
+
+// routeParamAttributes returns the span attributes of the request path parameters.
+func (o handlerOptions) routeParamAttributes(r *http.Request) []attribute.KeyValue {
+	params := o.routeParams(r)
+	attrs := make([]attribute.KeyValue, 0, len(params))
+	for name, value := range params {
+		attrs = append(attrs, attribute.String("http.route.param."+name, value))
+	}
+	return attrs
+}
+
+// route returns the http.route attribute value for the request.
+func (o handlerOptions) route(r *http.Request) string {
+	if o.routeNormalizer != nil {
+		if route := o.routeNormalizer(r); route != "" {
+			return route
+		}
+	}
+	if route := patternRoute(r.Pattern); route != "" {
+		return route
+	}
+	return r.URL.Path
+}
+
+// activeRoute returns the http.route attribute value of the active requests gauge, computed
+// before the handler runs. Unlike route, it never falls back to the unbounded raw URL path.
+func (o handlerOptions) activeRoute(r *http.Request) string {
+	if route := o.routeNormalizer(r); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// patternRoute extracts the path template from an http.ServeMux pattern (Go 1.22+),
+// dropping the optional method and host, e.g. "GET example.com/users/{id}" -> "/users/{id}".
+// The pattern is only set once the mux has matched the request, i.e. after the handler ran.
+func patternRoute(pattern string) string {
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		return pattern[i:]
+	}
+	return pattern
+}
+
+// routeBounded reports whether routes are normalized and thus safe for high-churn instruments.
+func (o handlerOptions) routeBounded() bool {
+	return o.routeNormalizer != nil
+}
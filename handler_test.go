@@ -1,15 +1,21 @@
 package o11y
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // --- Mocks for metric functions ---
@@ -126,6 +132,7 @@ func TestHandlerMiddleware(t *testing.T) {
 	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.method", "GET"))
 	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.route", "/test-route"))
 	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.Int("http.status_code", http.StatusOK))
+	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.status_class", "2xx"))
 
 	// Verify request duration
 	assert.Len(t, recordInFloat64HistogramCalls, 1)
@@ -228,4 +235,281 @@ func TestHandlerMiddlewarePanicRecovery(t *testing.T) {
 	assert.Contains(t, recordInFloat64HistogramCalls[0].Attributes, attribute.String("http.method", "GET"))
 	assert.Contains(t, recordInFloat64HistogramCalls[0].Attributes, attribute.String("http.route", "/panic-route"))
 	assert.Contains(t, recordInFloat64HistogramCalls[0].Attributes, attribute.Int("http.status_code", http.StatusInternalServerError))
+	assert.Contains(t, recordInFloat64HistogramCalls[0].Attributes, attribute.String("http.status_class", "5xx"))
+}
+
+// TestRecoveryMiddleware_TraceCorrelation verifies that panic logs carry the trace context exactly once,
+// whether or not LoggerMiddleware injected a correlated logger.
+func TestRecoveryMiddleware_TraceCorrelation(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	cfg := Config{}
+	panicHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+	chains := map[string]http.Handler{
+		"recovery only":       RecoveryMiddleware(cfg)(panicHandler),
+		"logger and recovery": LoggerMiddleware(cfg)(RecoveryMiddleware(cfg)(panicHandler)),
+	}
+
+	for name, h := range chains {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := zerolog.New(&buf).WithContext(context.Background())
+			ctx, span := tp.Tracer("test").Start(ctx, "request")
+			defer span.End()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil).WithContext(ctx))
+
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Contains(t, buf.String(), "HTTP request recovered from panic")
+			assert.Contains(t, buf.String(), `"trace_id":"`+span.SpanContext().TraceID().String()+`"`)
+			assert.Contains(t, buf.String(), `"span_id":"`+span.SpanContext().SpanID().String()+`"`)
+			assert.Equal(t, 1, strings.Count(buf.String(), `"trace_id"`))
+		})
+	}
+}
+
+func TestComposableMiddlewares(t *testing.T) {
+	resetMetricMocks()
+
+	var counterCalls int
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		counterCalls++
+	}
+	defer resetMetricMocks()
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+
+	panicHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("admin panic")
+	})
+
+	// Only recovery and logging, without otelhttp spans or metrics.
+	wrapped := LoggerMiddleware(cfg)(RecoveryMiddleware(cfg)(panicHandler))
+
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "INTERNAL_ERROR")
+	assert.Zero(t, counterCalls, "metrics should not be recorded without MetricsMiddleware")
+}
+
+func TestHandlerMiddleware_RouteNormalizer(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		addToInt64UpDownCounterCalls = append(addToInt64UpDownCounterCalls, struct {
+			Name       string
+			Value      int64
+			Attributes []attribute.KeyValue
+		}{Name: name, Value: value, Attributes: attributes})
+	}
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		addToIntCounterCalls = append(addToIntCounterCalls, struct {
+			Name       string
+			Value      int64
+			Attributes []attribute.KeyValue
+		}{Name: name, Value: value, Attributes: attributes})
+	}
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	normalizer := func(r *http.Request) string { return "/users/{id}" }
+
+	// Without a normalizer, active requests carry no route.
+	MetricsMiddleware(cfg)(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	// With a normalizer, both metrics carry the normalized route.
+	MetricsMiddleware(cfg, WithRouteNormalizer(normalizer))(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	assert.Len(t, addToInt64UpDownCounterCalls, 4)
+	assert.Empty(t, addToInt64UpDownCounterCalls[0].Attributes)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("http.route", "/users/{id}")}, addToInt64UpDownCounterCalls[2].Attributes)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("http.route", "/users/{id}")}, addToInt64UpDownCounterCalls[3].Attributes)
+
+	assert.Len(t, addToIntCounterCalls, 2)
+	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.route", "/users/42"))
+	assert.Contains(t, addToIntCounterCalls[1].Attributes, attribute.String("http.route", "/users/{id}"))
+
+	// A normalizer not knowing the route before the handler runs never puts the raw path on the gauge.
+	addToInt64UpDownCounterCalls = nil
+	lateNormalizer := func(r *http.Request) string { return patternRoute(r.Pattern) }
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", okHandler)
+	MetricsMiddleware(cfg, WithRouteNormalizer(lateNormalizer))(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	assert.Equal(t, []attribute.KeyValue{attribute.String("http.route", "unmatched")}, addToInt64UpDownCounterCalls[0].Attributes)
+	assert.Contains(t, addToIntCounterCalls[2].Attributes, attribute.String("http.route", "/users/{id}"))
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "1xx", statusClass(http.StatusContinue))
+	assert.Equal(t, "2xx", statusClass(http.StatusNoContent))
+	assert.Equal(t, "3xx", statusClass(http.StatusFound))
+	assert.Equal(t, "4xx", statusClass(http.StatusNotFound))
+	assert.Equal(t, "5xx", statusClass(http.StatusServiceUnavailable))
+	assert.Equal(t, "unknown", statusClass(0))
+	assert.Equal(t, "unknown", statusClass(700))
+}
+
+func TestHandlerMiddleware_ServeMuxPattern(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		addToIntCounterCalls = append(addToIntCounterCalls, struct {
+			Name       string
+			Value      int64
+			Attributes []attribute.KeyValue
+		}{Name: name, Value: value, Attributes: attributes})
+	}
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// The full Handler copies the request in LoggerMiddleware, so the pattern is handed back
+	// through the context, without modifying the request the middleware received.
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	Handler(cfg)(mux).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, req.Pattern)
+	// Unmatched requests fall back to the raw path.
+	Handler(cfg)(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Len(t, addToIntCounterCalls, 2)
+	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.route", "/users/{id}"))
+	assert.Contains(t, addToIntCounterCalls[1].Attributes, attribute.String("http.route", "/missing"))
+}
+
+func TestPatternRoute(t *testing.T) {
+	assert.Equal(t, "/users/{id}", patternRoute("/users/{id}"))
+	assert.Equal(t, "/users/{id}", patternRoute("GET /users/{id}"))
+	assert.Equal(t, "/static/", patternRoute("GET example.com/static/"))
+	assert.Equal(t, "", patternRoute(""))
+}
+
+func TestHandlerMiddleware_ContentTypes(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		addToIntCounterCalls = append(addToIntCounterCalls, struct {
+			Name       string
+			Value      int64
+			Attributes []attribute.KeyValue
+		}{Name: name, Value: value, Attributes: attributes})
+	}
+	var histogramAttrs []attribute.KeyValue
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		histogramAttrs = attributes
+	}
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+	middleware := MetricsMiddleware(cfg, WithContentTypes("application/json"))
+	for _, ct := range []string{"application/json; charset=utf-8", "text/x-bogus", ""} {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ct != "" {
+				w.Header().Set("Content-Type", ct)
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	assert.Len(t, addToIntCounterCalls, 3)
+	assert.Contains(t, addToIntCounterCalls[0].Attributes, attribute.String("http.response.content_type", "application/json"))
+	assert.Contains(t, addToIntCounterCalls[1].Attributes, attribute.String("http.response.content_type", "other"))
+	assert.Contains(t, addToIntCounterCalls[2].Attributes, attribute.String("http.response.content_type", "none"))
+	assert.NotContains(t, attributeKeys(histogramAttrs), attribute.Key("http.response.content_type"))
+}
+
+func TestHandlerMiddleware_Hosts(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	var activeAttrs, counterAttrs [][]attribute.KeyValue
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if value > 0 {
+			activeAttrs = append(activeAttrs, attributes)
+		}
+	}
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		counterAttrs = append(counterAttrs, attributes)
+	}
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+	handler := MetricsMiddleware(cfg, WithHosts("api.example.com"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, host := range []string{"API.example.com:8443", "spoofed.example.org"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	assert.Len(t, counterAttrs, 2)
+	assert.Contains(t, activeAttrs[0], attribute.String("server.address", "api.example.com"))
+	assert.Contains(t, counterAttrs[0], attribute.String("server.address", "api.example.com"))
+	assert.Contains(t, counterAttrs[1], attribute.String("server.address", "other"))
+}
+
+func TestHandlerMiddleware_RouteParams(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	var counterAttrs []attribute.KeyValue
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		counterAttrs = attributes
+	}
+
+	sr := tracetest.NewSpanRecorder()
+	oldTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(oldTP)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{user_id}", func(w http.ResponseWriter, r *http.Request) {})
+	cfg := Config{Enabled: true, Service: "test-service"}
+	handler := Handler(cfg, WithRouteParams(func(r *http.Request) map[string]string {
+		return map[string]string{"user_id": r.PathValue("user_id")}
+	}))(mux)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.route.param.user_id", "42"))
+	assert.Contains(t, counterAttrs, attribute.String("http.route", "/users/{user_id}"))
+	assert.NotContains(t, attributeKeys(counterAttrs), attribute.Key("http.route.param.user_id"))
+
+	// The route survives middlewares copying the request, such as TimeoutMiddleware.
+	handler = Handler(cfg, WithRouteParams(func(r *http.Request) map[string]string {
+		return map[string]string{"user_id": r.PathValue("user_id")}
+	}))(TimeoutMiddleware(time.Second)(mux))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+
+	spans = sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Contains(t, spans[1].Attributes(), attribute.String("http.route.param.user_id", "7"))
+	assert.Contains(t, counterAttrs, attribute.String("http.route", "/users/{user_id}"))
+}
+
+func TestNormalizeHost(t *testing.T) {
+	assert.Equal(t, "example.com", normalizeHost("Example.COM:8080"))
+	assert.Equal(t, "example.com", normalizeHost("example.com."))
+	assert.Equal(t, "::1", normalizeHost("[::1]:80"))
 }
@@ -0,0 +1,58 @@
+package o11y
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+
+	tc "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// deterministicIDGenerator generates trace and span IDs from a seeded pseudo-random source.
+type deterministicIDGenerator struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewDeterministicIDGenerator returns an IDGenerator producing the same sequence of IDs
+// for the same seed, for use as TraceConfig.IDGenerator in tests. Combined with a fixed
+// SampleRatio, the n-th operation is then always, or never, sampled.
+// It must not be used in production, where IDs have to be unpredictable and unique across processes.
+func NewDeterministicIDGenerator(seed uint64) tc.IDGenerator {
+	return &deterministicIDGenerator{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// NewIDs implements tc.IDGenerator.
+func (g *deterministicIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var tid trace.TraceID
+	for !tid.IsValid() {
+		g.fill(tid[:])
+	}
+	return tid, g.newSpanID()
+}
+
+// NewSpanID implements tc.IDGenerator.
+func (g *deterministicIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.newSpanID()
+}
+
+// newSpanID returns a valid span ID. g.mu must be held.
+func (g *deterministicIDGenerator) newSpanID() trace.SpanID {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		g.fill(sid[:])
+	}
+	return sid
+}
+
+// fill fills b with pseudo-random bytes. g.mu must be held.
+func (g *deterministicIDGenerator) fill(b []byte) {
+	for i := range b {
+		b[i] = byte(g.rng.Uint32())
+	}
+}
@@ -0,0 +1,48 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// sampledSequence starts n root spans with a deterministic generator and returns their sampling decisions.
+func sampledSequence(t *testing.T, seed uint64, n int) []bool {
+	t.Helper()
+	cfg := TraceConfig{
+		Enabled:     true,
+		Exporter:    "none",
+		SampleRatio: 0.5,
+		IDGenerator: NewDeterministicIDGenerator(seed),
+	}
+	tp, shutdown, err := setupTracing(cfg, resource.Default())
+	assert.NoError(t, err)
+	defer shutdown(context.Background())
+
+	decisions := make([]bool, n)
+	for i := range decisions {
+		_, span := tp.Tracer("test").Start(context.Background(), "op")
+		decisions[i] = span.SpanContext().IsSampled()
+		span.End()
+	}
+	return decisions
+}
+
+func TestDeterministicIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	a, b := NewDeterministicIDGenerator(42), NewDeterministicIDGenerator(42)
+	tidA, sidA := a.NewIDs(ctx)
+	tidB, sidB := b.NewIDs(ctx)
+	assert.Equal(t, tidA, tidB)
+	assert.Equal(t, sidA, sidB)
+	assert.True(t, tidA.IsValid())
+	assert.Equal(t, a.NewSpanID(ctx, tidA), b.NewSpanID(ctx, tidB))
+
+	// The same seed yields the same sampling decisions under a fixed ratio.
+	first := sampledSequence(t, 7, 20)
+	assert.Equal(t, first, sampledSequence(t, 7, 20))
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
@@ -0,0 +1,43 @@
+package o11y
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// localValueDesc describes the o11y_local_value gauge exposed by NewLocalValuesCollector.
+var localValueDesc = prometheus.NewDesc(
+	"o11y_local_value",
+	"In-process value of an o11y metric, as returned by o11y.GetMetricValue.",
+	[]string{"metric"}, nil,
+)
+
+// localValuesCollector exposes the in-process metric values as Prometheus gauges.
+type localValuesCollector struct{}
+
+// NewLocalValuesCollector returns a prometheus.Collector exposing the in-process values of the
+// o11y metrics (see MetricSnapshot) as the gauge o11y_local_value, with the o11y metric name in
+// the "metric" label, e.g. o11y_local_value{metric="biz.operation.error.total"} 3.
+// It lets setups built on the classic Prometheus client expose the library's counters on their
+// own registry, without the OTel Prometheus exporter. Values are read at every scrape.
+//
+// Example:
+//
+//	prometheus.MustRegister(o11y.NewLocalValuesCollector())
+func NewLocalValuesCollector() prometheus.Collector {
+	return localValuesCollector{}
+}
+
+// Describe implements prometheus.Collector.
+func (localValuesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- localValueDesc
+}
+
+// Collect implements prometheus.Collector.
+func (localValuesCollector) Collect(ch chan<- prometheus.Metric) {
+	localValues.Range(func(name string, val *atomic.Int64) bool {
+		ch <- prometheus.MustNewConstMetric(localValueDesc, prometheus.GaugeValue, float64(val.Load()), name)
+		return true
+	})
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"io"
 	"os"
+	"os/signal"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -27,6 +30,42 @@ var DefaultLogIgnore = []string{
 	// Filter our own middleware and hooks to avoid clutter.
 	"o11y.(*Middleware).serveHTTP", // This is a forward reference to a future file, which is fine.
 	"o11y.initialization.PanicHook",
+	"github.com/oy3o/o11y.StackHook.",
+}
+
+// Default log field names of the trace and span IDs, see LogConfig.TraceIDKey.
+const (
+	DefaultTraceIDKey = "trace_id"
+	DefaultSpanIDKey  = "span_id"
+)
+
+// spanFields holds the configured span log fields, replaced as a whole by setupLogging so
+// loggers built concurrently never see a mix of two configurations. Nil means the defaults.
+var spanFields atomic.Pointer[spanFieldsConfig]
+
+// spanFieldsConfig is the effective configuration of the span fields added to loggers.
+type spanFieldsConfig struct {
+	// traceIDKey and spanIDKey are the log field names of the trace and span IDs.
+	traceIDKey, spanIDKey string
+	// traceparent reports whether the traceparent field is added too.
+	traceparent bool
+}
+
+// defaultSpanFields is used until setupLogging runs.
+var defaultSpanFields = spanFieldsConfig{traceIDKey: DefaultTraceIDKey, spanIDKey: DefaultSpanIDKey}
+
+// setSpanFields publishes the span log fields of cfg to the logger constructors.
+func setSpanFields(cfg LogConfig) {
+	traceKey, spanKey := cfg.idKeys()
+	spanFields.Store(&spanFieldsConfig{traceIDKey: traceKey, spanIDKey: spanKey, traceparent: cfg.EnableTraceparent})
+}
+
+// loadSpanFields returns the current span log fields.
+func loadSpanFields() *spanFieldsConfig {
+	if f := spanFields.Load(); f != nil {
+		return f
+	}
+	return &defaultSpanFields
 }
 
 // setupLogging configures and creates the primary zerolog.Logger instance based on LogConfig.
@@ -42,6 +81,8 @@ func setupLogging(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
 	}
 	zerolog.SetGlobalLevel(level)
 
+	setSpanFields(cfg)
+
 	// 2. Set the global time field format for performance.
 	// Using Unix timestamps is generally faster and produces smaller log entries.
 	switch cfg.TimePrecision {
@@ -73,41 +114,66 @@ func setupLogging(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
 				MaxAge:     cfg.FileRotation.MaxAge,
 				Compress:   cfg.FileRotation.Compress,
 			}
-			writers = append(writers, fileWriter)
+			if cfg.FileBufferSize > 0 {
+				// Flushed before the file is closed below.
+				buffered := newBufferedFileWriter(fileWriter, cfg.FileBufferSize)
+				writers = append(writers, buffered)
+				closers = append(closers, buffered)
+			} else {
+				writers = append(writers, fileWriter)
+			}
 			closers = append(closers, fileWriter) // lumberjack.Logger implements io.Closer
+
+			// Make the file available to RotateLogs, optionally triggered by SIGHUP.
+			logFile.Store(fileWriter)
+			closers = append(closers, closerFunc(func() error {
+				logFile.CompareAndSwap(fileWriter, nil)
+				return nil
+			}))
+			if cfg.FileRotation.RotateOnSIGHUP {
+				closers = append(closers, rotateOnSignal(syscall.SIGHUP))
+			}
 		}
 	}
 
 	// 4. Configure console output.
 	// To prevent accidental loss of logs, we default to console output if no other writer is configured.
 	if cfg.EnableConsole || len(writers) == 0 {
-		writers = append(writers, zerolog.ConsoleWriter{
+		consoleWriter := zerolog.ConsoleWriter{
 			Out:        os.Stdout,
 			TimeFormat: time.RFC3339, // Human-friendly time format for console.
-		})
+		}
+		if cfg.ConsoleNoTimestamp {
+			consoleWriter.PartsExclude = []string{zerolog.TimestampFieldName}
+		}
+		writers = append(writers, consoleWriter)
+	}
+
+	// Keep the recent events for a crash dump. It does not count as an output above.
+	if cfg.CrashBufferSize > 0 {
+		ring := newCrashBuffer(cfg.CrashBufferSize, cfg.CrashDumpFile)
+		writers = append(writers, ring)
+		crashBuffer.Store(ring)
+		closers = append(closers, closerFunc(func() error {
+			crashBuffer.CompareAndSwap(ring, nil)
+			return nil
+		}))
 	}
 
 	// 5. Create the logger instance with all configured writers.
 	// MultiLevelWriter sends logs to all writers in the slice.
-	multiWriter := zerolog.MultiLevelWriter(writers...)
+	var multiWriter zerolog.LevelWriter = zerolog.MultiLevelWriter(writers...)
+	if cfg.FlushOnError {
+		multiWriter = newFlushOnErrorWriter(multiWriter, writers)
+	}
+	// Long string values are truncated once, before the event is fanned out.
+	multiWriter = newTruncatingWriter(multiWriter, cfg.MaxFieldLength)
 	logger := zerolog.New(multiWriter)
 
 	// 6. Add caller information if enabled.
 	// This adds a slight performance overhead, so it's best used during development.
 	if cfg.EnableCaller {
-		// Optimize the caller output to be just "file:line", removing the long path.
-		// This improves readability in console logs.
-		zerolog.CallerMarshalFunc = func(pc uintptr, file string, line int) string {
-			// Simple basename implementation to avoid importing path/filepath
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			return short + ":" + strconv.Itoa(line)
-		}
+		zerolog.CallerMarshalFunc = callerMarshalFunc(cfg.CallerFormat)
 		logger = logger.With().Caller().Logger()
 	}
 
@@ -127,61 +193,337 @@ func setupLogging(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
 	return logger, shutdown
 }
 
+// logFile is the log file opened by the most recent Init, if file logging is enabled.
+var logFile atomic.Pointer[lumberjack.Logger]
+
+// RotateLogs closes the current log file, renames it with a timestamp and opens a new one,
+// regardless of its size. Old files are then cleaned up according to FileRotationConfig.
+// It is a no-op if file logging is not enabled.
+//
+// Use it to coordinate with external log management; set FileRotationConfig.RotateOnSIGHUP
+// to have it called on SIGHUP instead.
+func RotateLogs() error {
+	f := logFile.Load()
+	if f == nil {
+		return nil
+	}
+	return f.Rotate()
+}
+
+// rotateOnSignal calls RotateLogs whenever sig is received, until the returned closer is closed.
+func rotateOnSignal(sig os.Signal) io.Closer {
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigCh, sig)
+
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				if err := RotateLogs(); err != nil {
+					log.Error().Err(err).Msg("Failed to rotate log file on signal")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return closerFunc(func() error {
+		signal.Stop(sigCh)
+		close(done)
+		return nil
+	})
+}
+
+// closerFunc adapts a function to io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// callerMarshalFunc returns a zerolog.CallerMarshalFunc rendering the caller in the given format.
+// See LogConfig.CallerFormat for the supported values.
+func callerMarshalFunc(format string) func(pc uintptr, file string, line int) string {
+	switch format {
+	case "full":
+		return func(pc uintptr, file string, line int) string {
+			return file + ":" + strconv.Itoa(line)
+		}
+	case "dir":
+		// Keep the last directory so files with the same name in different packages are distinguishable.
+		return func(pc uintptr, file string, line int) string {
+			short := file
+			if i := strings.LastIndexByte(file, '/'); i > 0 {
+				if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+					short = file[j+1:]
+				}
+			}
+			return short + ":" + strconv.Itoa(line)
+		}
+	case "short", "":
+	default:
+		log.Warn().Msgf("Invalid caller format '%s', defaulting to 'short'", format)
+	}
+
+	// Optimize the caller output to be just "file:line", removing the long path.
+	// This improves readability in console logs.
+	return func(pc uintptr, file string, line int) string {
+		// Simple basename implementation to avoid importing path/filepath
+		short := file
+		for i := len(file) - 1; i > 0; i-- {
+			if file[i] == '/' {
+				short = file[i+1:]
+				break
+			}
+		}
+		return short + ":" + strconv.Itoa(line)
+	}
+}
+
 // PanicHook creates a zerolog.Hook that, when a panic-level event is logged,
 // captures the current goroutine's stack trace, filters it for clarity,
 // and adds it to the log event under the "stack" key.
-func PanicHook(ignore []string) zerolog.Hook {
+//
+// Note that zerolog calls panic() after writing a panic-level event (and os.Exit after a
+// fatal-level one), which can be surprising inside recovered code. To get stack traces
+// without panic-level logging, use StackHook with a lower level.
+func PanicHook(ignore []string, opts ...StackFilterOption) zerolog.Hook {
+	return StackHook(zerolog.PanicLevel, ignore, opts...)
+}
+
+// StackHook is like PanicHook, but adds the filtered stack trace to every event logged at
+// minLevel or above, e.g. zerolog.ErrorLevel to get the stack of every logged error.
+// Capturing a stack is relatively expensive, so keep minLevel high on hot paths.
+// The options are passed to FilterStackTrace.
+func StackHook(minLevel zerolog.Level, ignore []string, opts ...StackFilterOption) zerolog.Hook {
 	// If no custom filters are provided, use the sensible defaults.
 	if len(ignore) == 0 {
 		ignore = DefaultLogIgnore
 	}
 	return zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, msg string) {
-		if level == zerolog.PanicLevel {
-			stack := FilterStackTrace(string(debug.Stack()), ignore)
+		// Levels above panic (NoLevel, Disabled) are not severities.
+		if level >= minLevel && level <= zerolog.PanicLevel {
+			stack := FilterStackTrace(string(debug.Stack()), ignore, opts...)
 			e.Str("stack", stack)
 		}
 	})
 }
 
+// maxErrorChain bounds the number of causes recorded by ErrorChain.
+const maxErrorChain = 32
+
+// ErrorChain flattens err and all the errors it wraps, via fmt.Errorf("%w") or errors.Join,
+// into a list of messages in depth-first order, starting with err itself.
+// At most 32 entries are returned.
+func ErrorChain(err error) []string {
+	var chain []string
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil || len(chain) >= maxErrorChain {
+			return
+		}
+		chain = append(chain, err.Error())
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(x.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, e := range x.Unwrap() {
+				walk(e)
+			}
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// WithErrorChain decorates a log event with err as the "error" field and its causes,
+// as returned by ErrorChain, as the "error_chain" array field. This lets log backends
+// search for a root cause without parsing a single flattened message.
+//
+// Example:
+//
+//	o11y.WithErrorChain(logger.Error(), err).Msg("Failed to load config")
+func WithErrorChain(e *zerolog.Event, err error) *zerolog.Event {
+	return e.Err(err).Strs("error_chain", ErrorChain(err))
+}
+
+// LogFunc adapts the logger of ctx, as returned by GetLoggerFromContext, to the printf-style
+// func(format string, args ...any) signature expected by many third-party packages.
+// Messages are logged at info level and keep the trace correlation fields of ctx.
+//
+// Example:
+//
+//	client := legacy.NewClient(legacy.WithLogger(o11y.LogFunc(ctx)))
+func LogFunc(ctx context.Context) func(format string, args ...any) {
+	return LogFuncLevel(ctx, zerolog.InfoLevel)
+}
+
+// LogFuncLevel is like LogFunc, but logs at the given level.
+// Fatal and panic levels are recorded with that level but never exit or panic.
+func LogFuncLevel(ctx context.Context, level zerolog.Level) func(format string, args ...any) {
+	logger := GetLoggerFromContext(ctx)
+	return func(format string, args ...any) {
+		// Skip this closure so the caller field points at the legacy code.
+		logger.WithLevel(level).CallerSkipFrame(1).Msgf(format, args...)
+	}
+}
+
+// StackFilterOption customizes the behavior of FilterStackTrace and FilterStackTraceWriter.
+type StackFilterOption func(*stackFilterOptions)
+
+type stackFilterOptions struct {
+	collapse bool
+}
+
+// WithCollapsedFrames collapses consecutive identical frames into a single frame
+// annotated with the repeat count, e.g. "main.recurse(0x64) (x120)".
+// Frames are identical when they share the function and the file:line, whatever their
+// arguments and program counter offsets; the first one of a run is kept.
+// This keeps the relevant frames readable in recursion-induced panics.
+func WithCollapsedFrames() StackFilterOption {
+	return func(o *stackFilterOptions) {
+		o.collapse = true
+	}
+}
+
 // FilterStackTrace cleans a raw stack trace string by removing irrelevant frames.
 // It takes the raw stack and a slice of prefixes to ignore.
 // It works by processing the stack trace in pairs of lines (function call and file path).
-func FilterStackTrace(stack string, ignore []string) string {
+func FilterStackTrace(stack string, ignore []string, opts ...StackFilterOption) string {
+	var result strings.Builder
+	// strings.Builder never returns a write error.
+	_ = FilterStackTraceWriter(&result, stack, ignore, opts...)
+	return result.String()
+}
+
+// FilterStackTraceWriter is the streaming form of FilterStackTrace.
+// It writes the filtered stack to w frame by frame, without splitting the whole stack
+// up front or holding a filtered copy in memory. This matters for panics carrying
+// multi-megabyte stacks from thousands of goroutines.
+// It returns the first error encountered while writing to w.
+func FilterStackTraceWriter(w io.Writer, stack string, ignore []string, opts ...StackFilterOption) error {
 	// If no custom filters are provided, use the sensible defaults.
 	if len(ignore) == 0 {
 		ignore = DefaultLogIgnore
 	}
 
-	lines := strings.Split(stack, "\n")
-	if len(lines) < 2 {
-		return stack // Not a valid stack trace, return as is.
+	var o stackFilterOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	var result strings.Builder
-	// The first line is always "goroutine X [running]:", which we keep.
-	result.WriteString(lines[0] + "\n")
-
-	// Stack frames appear in pairs: the function call line, then the file:line path.
-	// We iterate through these pairs.
-	for i := 1; i+1 < len(lines); i += 2 {
-		funcLine := lines[i]
-		fileLine := strings.TrimSpace(lines[i+1])
-
-		isIgnored := false
-		for _, prefix := range ignore {
-			// Check if either line in the pair matches an ignore prefix.
-			if strings.HasPrefix(funcLine, prefix) || strings.Contains(fileLine, prefix) {
-				isIgnored = true
-				break
+	if !strings.Contains(stack, "\n") {
+		// Not a valid stack trace, write it as is.
+		_, err := io.WriteString(w, stack)
+		return err
+	}
+
+	var (
+		header   = true
+		pending  bool
+		funcLine string
+
+		// The last kept frame, its identity and how many times it repeated, used when collapsing.
+		lastFunc, lastFile string
+		lastKey            frameKey
+		repeats            int
+	)
+	for line := range strings.SplitSeq(stack, "\n") {
+		// The first line is always "goroutine X [running]:", which we keep.
+		if header {
+			header = false
+			if err := writeStackLine(w, line); err != nil {
+				return err
 			}
+			continue
 		}
 
-		if !isIgnored {
+		// Stack frames appear in pairs: the function call line, then the file:line path.
+		if !pending {
+			funcLine = line
+			pending = true
+			continue
+		}
+		pending = false
+		fileLine := strings.TrimSpace(line)
+
+		if isIgnoredFrame(funcLine, fileLine, ignore) {
+			continue
+		}
+
+		if !o.collapse {
 			// If the frame is relevant, add it to our result.
-			result.WriteString(funcLine + "\n")
-			result.WriteString(fileLine + "\n")
+			if err := writeStackFrame(w, funcLine, fileLine, 1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Hold the frame back until we know whether the next one repeats it.
+		key := newFrameKey(funcLine, fileLine)
+		if repeats > 0 && key == lastKey {
+			repeats++
+			continue
 		}
+		if repeats > 0 {
+			if err := writeStackFrame(w, lastFunc, lastFile, repeats); err != nil {
+				return err
+			}
+		}
+		lastFunc, lastFile, lastKey, repeats = funcLine, fileLine, key, 1
 	}
 
-	return result.String()
+	if repeats > 0 {
+		return writeStackFrame(w, lastFunc, lastFile, repeats)
+	}
+	return nil
+}
+
+// frameKey identifies a stack frame by its function and source position.
+type frameKey struct {
+	function, position string
+}
+
+// newFrameKey returns the identity of a frame pair, dropping the argument list of the
+// function line, e.g. "main.recurse(0x5)", and the program counter offset of the file line,
+// e.g. "/app/main.go:15 +0x25".
+func newFrameKey(funcLine, fileLine string) frameKey {
+	function := funcLine
+	if strings.HasSuffix(function, ")") {
+		if i := strings.LastIndexByte(function, '('); i > 0 {
+			function = function[:i]
+		}
+	}
+	position, _, _ := strings.Cut(fileLine, " +0x")
+	return frameKey{function: function, position: position}
+}
+
+// writeStackFrame writes a frame pair, annotating the function line with the repeat count if any.
+func writeStackFrame(w io.Writer, funcLine, fileLine string, repeats int) error {
+	if repeats > 1 {
+		funcLine += " (x" + strconv.Itoa(repeats) + ")"
+	}
+	if err := writeStackLine(w, funcLine); err != nil {
+		return err
+	}
+	return writeStackLine(w, fileLine)
+}
+
+// isIgnoredFrame checks if either line in a frame pair matches an ignore prefix.
+func isIgnoredFrame(funcLine, fileLine string, ignore []string) bool {
+	for _, prefix := range ignore {
+		if strings.HasPrefix(funcLine, prefix) || strings.Contains(fileLine, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStackLine writes a single line followed by a newline.
+func writeStackLine(w io.Writer, line string) error {
+	if _, err := io.WriteString(w, line); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
 }
@@ -0,0 +1,105 @@
+package o11y
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// crashBuffer is the buffer of the most recent Init, if LogConfig.CrashBufferSize is set.
+var crashBuffer atomic.Pointer[crashRing]
+
+// crashRing is a log writer keeping the last events written to it, dumped when the process
+// is about to crash. See LogConfig.CrashBufferSize.
+type crashRing struct {
+	mu     sync.Mutex
+	events [][]byte
+	next   int // index of the slot written next
+	count  int // number of events kept, up to len(events)
+
+	// path is the file the events are appended to; stderr is used if it is empty.
+	path   string
+	stderr io.Writer
+}
+
+func newCrashBuffer(size int, path string) *crashRing {
+	return &crashRing{events: make([][]byte, size), path: path, stderr: os.Stderr}
+}
+
+func (r *crashRing) Write(p []byte) (int, error) {
+	return r.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (r *crashRing) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	r.mu.Lock()
+	// Reuse the slot so a steady stream of events does not allocate.
+	r.events[r.next] = append(r.events[r.next][:0], p...)
+	r.next = (r.next + 1) % len(r.events)
+	r.count = min(r.count+1, len(r.events))
+	r.mu.Unlock()
+
+	// zerolog exits or panics right after writing such events.
+	if level == zerolog.FatalLevel || level == zerolog.PanicLevel {
+		// The event itself was kept; a failed dump is not the caller's error.
+		_ = r.dump()
+	}
+	return len(p), nil
+}
+
+// dump writes the kept events, oldest first, and forgets them, so the same events are not
+// dumped twice, e.g. by a panic-level event and then by DumpLogsOnPanic.
+func (r *crashRing) dump() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- o11y: last %d log events ---\n", r.count)
+	start := (r.next - r.count + len(r.events)) % len(r.events)
+	for i := range r.count {
+		buf.Write(r.events[(start+i)%len(r.events)])
+	}
+	buf.WriteString("--- o11y: end of log events ---\n")
+	r.count = 0
+
+	w := r.stderr
+	if r.path != "" {
+		f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// DumpLogsOnPanic writes the events kept by LogConfig.CrashBufferSize to stderr, or to
+// LogConfig.CrashDumpFile, if the calling goroutine is panicking, then resumes the panic.
+// An unrecovered panic ends the process without running the shutdown of Init, so defer it
+// at the top of main and of long-lived goroutines. It does nothing if the buffer is not enabled.
+//
+// Example:
+//
+//	func main() {
+//	    shutdown, _ := o11y.Init(cfg)
+//	    defer shutdown(context.Background())
+//	    defer o11y.DumpLogsOnPanic()
+//	    // ...
+//	}
+func DumpLogsOnPanic() {
+	if v := recover(); v != nil {
+		if r := crashBuffer.Load(); r != nil {
+			_ = r.dump()
+		}
+		panic(v)
+	}
+}
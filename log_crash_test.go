@@ -0,0 +1,63 @@
+package o11y
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashRing(t *testing.T) {
+	var stderr bytes.Buffer
+	ring := newCrashBuffer(2, "")
+	ring.stderr = &stderr
+	logger := zerolog.New(zerolog.MultiLevelWriter(ring))
+
+	logger.Info().Msg("first")
+	logger.Info().Msg("second")
+	logger.Warn().Msg("third")
+	assert.Empty(t, stderr.String())
+
+	// A panic-level event dumps the last events, including itself.
+	assert.Panics(t, func() { logger.Panic().Msg("boom") })
+	dump := stderr.String()
+	assert.Contains(t, dump, "last 2 log events")
+	assert.NotContains(t, dump, "second")
+	assert.Less(t, strings.Index(dump, "third"), strings.Index(dump, "boom"))
+
+	// Dumped events are not dumped again.
+	stderr.Reset()
+	require.NoError(t, ring.dump())
+	assert.Empty(t, stderr.String())
+}
+
+func TestDumpLogsOnPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crash.log")
+	ring := newCrashBuffer(10, path)
+	crashBuffer.Store(ring)
+	t.Cleanup(func() { crashBuffer.Store(nil) })
+	logger := zerolog.New(ring)
+	logger.Info().Msg("before the crash")
+
+	assert.PanicsWithValue(t, "crash", func() {
+		defer DumpLogsOnPanic()
+		panic("crash")
+	})
+	dump, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), "before the crash")
+
+	// Without a panic nothing is dumped.
+	logger.Info().Msg("after")
+	func() {
+		defer DumpLogsOnPanic()
+	}()
+	dump, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(dump), "after")
+}
@@ -0,0 +1,113 @@
+package o11y
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// flusher is implemented by log writers that buffer output, such as bufferedFileWriter.
+type flusher interface {
+	Flush() error
+}
+
+// fileFlushInterval is how often bufferedFileWriter flushes on its own.
+const fileFlushInterval = time.Second
+
+// bufferedFileWriter buffers the writes to the log file in memory, see LogConfig.FileBufferSize.
+// The buffer is flushed when full, every fileFlushInterval, on Flush and on Close.
+type bufferedFileWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newBufferedFileWriter buffers up to size bytes of writes to w.
+func newBufferedFileWriter(w io.Writer, size int) *bufferedFileWriter {
+	b := &bufferedFileWriter{
+		w:    bufio.NewWriterSize(w, size),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// run flushes the buffer periodically, so a quiet service does not hold its last events back.
+func (b *bufferedFileWriter) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(fileFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Flush()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *bufferedFileWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Write(p)
+}
+
+// Flush writes the buffered events to the file.
+func (b *bufferedFileWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Flush()
+}
+
+// Close stops the periodic flush and flushes what is left. It does not close the file.
+func (b *bufferedFileWriter) Close() error {
+	close(b.stop)
+	<-b.done
+	return b.Flush()
+}
+
+// flushOnErrorWriter flushes the buffered writers after every event logged at error level or
+// above, so the event survives a crash that follows it. It is a writer rather than a zerolog
+// hook because hooks run before the event is written, and would flush everything but the event.
+type flushOnErrorWriter struct {
+	w        zerolog.LevelWriter
+	flushers []flusher
+}
+
+// newFlushOnErrorWriter wraps w, flushing those of writers that buffer output.
+// It returns w as is if none does.
+func newFlushOnErrorWriter(w zerolog.LevelWriter, writers []io.Writer) zerolog.LevelWriter {
+	var flushers []flusher
+	for _, writer := range writers {
+		if f, ok := writer.(flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	if len(flushers) == 0 {
+		return w
+	}
+	return &flushOnErrorWriter{w: w, flushers: flushers}
+}
+
+func (f *flushOnErrorWriter) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+func (f *flushOnErrorWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	n, err := f.w.WriteLevel(level, p)
+	// Levels above panic (NoLevel, Disabled) are not severities.
+	if level >= zerolog.ErrorLevel && level <= zerolog.PanicLevel {
+		for _, fl := range f.flushers {
+			// A failed flush is not the caller's error: the event was written.
+			_ = fl.Flush()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,80 @@
+package o11y
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferedWriter is a log writer that counts its flushes.
+type bufferedWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *bufferedWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func TestFlushOnErrorWriter(t *testing.T) {
+	buffered := &bufferedWriter{}
+	writers := []io.Writer{buffered, io.Discard}
+	logger := zerolog.New(newFlushOnErrorWriter(zerolog.MultiLevelWriter(writers...), writers))
+
+	logger.Info().Msg("ok")
+	logger.Warn().Msg("slow")
+	assert.Equal(t, 0, buffered.flushes)
+
+	logger.Error().Msg("failed")
+	assert.Equal(t, 1, buffered.flushes)
+	assert.Contains(t, buffered.String(), "failed")
+
+	// Without buffered writers, the writer is returned as is.
+	w := zerolog.MultiLevelWriter(io.Discard)
+	assert.Equal(t, w, newFlushOnErrorWriter(w, []io.Writer{io.Discard}))
+}
+
+// TestFileBufferSize verifies that buffered file output is written on error events with
+// FlushOnError, and on shutdown otherwise.
+func TestFileBufferSize(t *testing.T) {
+	level, timeFormat := zerolog.GlobalLevel(), zerolog.TimeFieldFormat
+	t.Cleanup(func() {
+		zerolog.SetGlobalLevel(level)
+		zerolog.TimeFieldFormat = timeFormat
+	})
+
+	for _, flushOnError := range []bool{false, true} {
+		filename := filepath.Join(t.TempDir(), "app.log")
+		logger, shutdown := setupLogging(LogConfig{
+			Level:          "info",
+			EnableFile:     true,
+			FileRotation:   FileRotationConfig{Filename: filename},
+			FileBufferSize: 64 << 10,
+			FlushOnError:   flushOnError,
+		})
+		read := func() string {
+			data, _ := os.ReadFile(filename)
+			return string(data)
+		}
+
+		logger.Info().Msg("started")
+		logger.Error().Msg("failed")
+		if flushOnError {
+			assert.Contains(t, read(), "started")
+			assert.Contains(t, read(), "failed")
+		} else {
+			assert.Empty(t, read())
+		}
+
+		require.NoError(t, shutdown(context.Background()))
+		assert.Contains(t, read(), "failed")
+	}
+}
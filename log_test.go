@@ -3,9 +3,15 @@ package o11y_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,6 +65,27 @@ func TestInit_Logging_Configuration(t *testing.T) {
 				assert.Empty(t, content, "File should not be written to")
 			},
 		},
+		{
+			name: "Should_omit_console_timestamp_when_configured",
+			config: o11y.Config{
+				Enabled: true,
+				Log: o11y.LogConfig{
+					Level:              "info",
+					EnableConsole:      true,
+					ConsoleNoTimestamp: true,
+				},
+			},
+			logAction: func() {
+				log.Info().Msg("no timestamp")
+			},
+			assertConsole: func(t *testing.T, output string) {
+				assert.Contains(t, output, "no timestamp")
+				assert.NotRegexp(t, `\d{4}-\d{2}-\d{2}T`, output, "RFC3339 timestamp should be omitted")
+			},
+			assertFile: func(t *testing.T, content string) {
+				assert.Empty(t, content, "File should not be written to")
+			},
+		},
 		{
 			name: "Should_log_to_both_console_and_file",
 			config: o11y.Config{
@@ -203,3 +230,343 @@ func TestInit_Logging_Level(t *testing.T) {
 		})
 	}
 }
+
+// TestInit_Logging_CallerFormat 测试不同 CallerFormat 下 caller 字段的格式
+func TestInit_Logging_CallerFormat(t *testing.T) {
+	originalLogger := log.Logger
+	originalMarshal := zerolog.CallerMarshalFunc
+	t.Cleanup(func() {
+		log.Logger = originalLogger
+		zerolog.CallerMarshalFunc = originalMarshal
+	})
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{"Should_use_basename_by_default", "", `"caller":"log_test.go:`},
+		{"Should_use_basename_for_short", "short", `"caller":"log_test.go:`},
+		{"Should_include_parent_dir_for_dir", "dir", `"caller":"` + filepath.Base(wd) + `/log_test.go:`},
+		{"Should_use_full_path_for_full", "full", `"caller":"` + filepath.ToSlash(wd) + `/log_test.go:`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "caller.log")
+			shutdown, err := o11y.Init(o11y.Config{
+				Enabled: true,
+				Log: o11y.LogConfig{
+					Level:        "info",
+					EnableCaller: true,
+					CallerFormat: tc.format,
+					EnableFile:   true,
+					FileRotation: o11y.FileRotationConfig{Filename: logFile},
+				},
+			})
+			require.NoError(t, err)
+
+			log.Info().Msg("where am I")
+			require.NoError(t, shutdown(context.Background()))
+
+			content, err := os.ReadFile(logFile)
+			require.NoError(t, err)
+			assert.Contains(t, string(content), tc.expected)
+		})
+	}
+}
+
+// TestFilterStackTraceWriter 测试流式过滤与字符串版本的输出一致，并能传递写入错误
+func TestFilterStackTraceWriter(t *testing.T) {
+	stack := `goroutine 1 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:26 +0x5e
+main.handler()
+	/app/main.go:42 +0x1d
+net/http.HandlerFunc.ServeHTTP(...)
+	/usr/local/go/src/net/http/server.go:2294 +0x29
+main.main()
+	/app/main.go:10 +0x25
+`
+	expected := "goroutine 1 [running]:\nmain.handler()\n/app/main.go:42 +0x1d\nmain.main()\n/app/main.go:10 +0x25\n"
+
+	var buf bytes.Buffer
+	require.NoError(t, o11y.FilterStackTraceWriter(&buf, stack, nil))
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, expected, o11y.FilterStackTrace(stack, nil))
+
+	// 不是合法的堆栈时原样输出
+	assert.Equal(t, "not a stack", o11y.FilterStackTrace("not a stack", nil))
+
+	// 写入错误会被返回
+	err := o11y.FilterStackTraceWriter(failingWriter{}, stack, nil)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+
+// TestFilterStackTrace_CollapsedFrames 测试递归产生的重复帧会被折叠
+func TestFilterStackTrace_CollapsedFrames(t *testing.T) {
+	var stack strings.Builder
+	stack.WriteString("goroutine 1 [running]:\n")
+	for range 100 {
+		stack.WriteString("main.recurse(...)\n\t/app/main.go:15 +0x25\n")
+	}
+	stack.WriteString("main.main()\n\t/app/main.go:10 +0x25\n")
+	stack.WriteString("main.recurse(...)\n\t/app/main.go:15 +0x25\n")
+
+	expected := "goroutine 1 [running]:\n" +
+		"main.recurse(...) (x100)\n/app/main.go:15 +0x25\n" +
+		"main.main()\n/app/main.go:10 +0x25\n" +
+		"main.recurse(...)\n/app/main.go:15 +0x25\n"
+	assert.Equal(t, expected, o11y.FilterStackTrace(stack.String(), nil, o11y.WithCollapsedFrames()))
+
+	// 默认不折叠
+	assert.Equal(t, 101, strings.Count(o11y.FilterStackTrace(stack.String(), nil), "main.recurse(...)\n"))
+
+	// 参数与 PC 偏移不同但函数和 file:line 相同的帧同样折叠，保留第一帧
+	stack.Reset()
+	stack.WriteString("goroutine 1 [running]:\n")
+	for i := range 3 {
+		fmt.Fprintf(&stack, "main.(*tree).walk(0x%x, 0x%x)\n\t/app/tree.go:21 +0x%x\n", i, i+1, 0x40+i)
+	}
+	stack.WriteString("main.(*tree).walk(0x9, 0xa)\n\t/app/tree.go:30 +0x40\n")
+
+	expected = "goroutine 1 [running]:\n" +
+		"main.(*tree).walk(0x0, 0x1) (x3)\n/app/tree.go:21 +0x40\n" +
+		"main.(*tree).walk(0x9, 0xa)\n/app/tree.go:30 +0x40\n"
+	assert.Equal(t, expected, o11y.FilterStackTrace(stack.String(), nil, o11y.WithCollapsedFrames()))
+}
+
+// TestRotateLogs 测试 RotateLogs 会轮转当前日志文件，且在未启用文件日志时为空操作
+func TestRotateLogs(t *testing.T) {
+	t.Cleanup(func() {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	})
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+
+	shutdown, err := o11y.Init(o11y.Config{
+		Enabled: true,
+		Log: o11y.LogConfig{
+			Level:        "info",
+			EnableFile:   true,
+			FileRotation: o11y.FileRotationConfig{Filename: logFile},
+		},
+	})
+	require.NoError(t, err)
+
+	log.Info().Msg("before rotation")
+	require.NoError(t, o11y.RotateLogs())
+	log.Info().Msg("after rotation")
+	require.NoError(t, shutdown(context.Background()))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "rotation should keep a timestamped backup next to the new file")
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "after rotation")
+	assert.NotContains(t, string(content), "before rotation")
+
+	// 关闭后 RotateLogs 不再作用于旧文件
+	assert.NoError(t, o11y.RotateLogs())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+// TestWithErrorChain 测试错误链会被展开为结构化的 error_chain 数组
+func TestWithErrorChain(t *testing.T) {
+	errDisk := errors.New("disk full")
+	errNet := errors.New("network down")
+	err := fmt.Errorf("save order: %w", errors.Join(errDisk, errNet))
+
+	assert.Equal(t, []string{
+		"save order: disk full\nnetwork down",
+		"disk full\nnetwork down",
+		"disk full",
+		"network down",
+	}, o11y.ErrorChain(err))
+	assert.Nil(t, o11y.ErrorChain(nil))
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	o11y.WithErrorChain(logger.Error(), fmt.Errorf("load: %w", errDisk)).Msg("failed")
+
+	assert.Contains(t, buf.String(), `"error":"load: disk full"`)
+	assert.Contains(t, buf.String(), `"error_chain":["load: disk full","disk full"]`)
+}
+
+// TestLogFunc 测试 printf 风格的适配器使用 Context 中的 Logger 并保留调用方信息
+func TestLogFunc(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Str("trace_id", "abc").Caller().Logger()
+	ctx := logger.WithContext(context.Background())
+
+	logf := o11y.LogFunc(ctx)
+	logf("retrying %s in %d ms", "upload", 200)
+
+	out := buf.String()
+	assert.Contains(t, out, `"level":"info"`)
+	assert.Contains(t, out, `"trace_id":"abc"`)
+	assert.Contains(t, out, `"message":"retrying upload in 200 ms"`)
+	assert.Contains(t, out, "log_test.go", "caller should point at the code calling the adapter")
+
+	buf.Reset()
+	o11y.LogFuncLevel(ctx, zerolog.WarnLevel)("slow query: %v", "SELECT 1")
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+	assert.Contains(t, buf.String(), `"message":"slow query: SELECT 1"`)
+}
+
+// TestStackFilters 测试自定义堆栈过滤规则的替换与追加两种模式
+func TestStackFilters(t *testing.T) {
+	panicStack := func(cfg o11y.LogConfig) string {
+		var buf bytes.Buffer
+		ctx := zerolog.New(&buf).WithContext(context.Background())
+		h := o11y.RecoveryMiddleware(o11y.Config{Log: cfg})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		var entry struct{ Stack string }
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry.Stack
+	}
+
+	// 默认规则：过滤 runtime/panic.go，保留中间件帧
+	stack := panicStack(o11y.LogConfig{})
+	assert.NotContains(t, stack, "runtime/panic.go")
+	assert.Contains(t, stack, "github.com/oy3o/o11y.RecoveryMiddleware")
+
+	// 替换模式：只使用自定义规则，默认规则失效
+	stack = panicStack(o11y.LogConfig{StackFilters: []string{"github.com/oy3o/o11y.RecoveryMiddleware"}})
+	assert.Contains(t, stack, "runtime/panic.go")
+	assert.NotContains(t, stack, "github.com/oy3o/o11y.RecoveryMiddleware")
+
+	// 追加模式：自定义规则与默认规则同时生效
+	stack = panicStack(o11y.LogConfig{StackFilters: []string{"github.com/oy3o/o11y.RecoveryMiddleware"}, StackFiltersAppend: true})
+	assert.NotContains(t, stack, "runtime/panic.go")
+	assert.NotContains(t, stack, "github.com/oy3o/o11y.RecoveryMiddleware")
+}
+
+// recursePanic 递归 depth 层后 panic，用于产生重复的堆栈帧
+func recursePanic(depth int) {
+	if depth == 0 {
+		panic("deep")
+	}
+	recursePanic(depth - 1)
+}
+
+// TestCollapseStackFrames 测试 LogConfig.CollapseStackFrames 会折叠 RecoveryMiddleware 记录的递归帧
+func TestCollapseStackFrames(t *testing.T) {
+	panicStack := func(cfg o11y.LogConfig) string {
+		var buf bytes.Buffer
+		ctx := zerolog.New(&buf).WithContext(context.Background())
+		h := o11y.RecoveryMiddleware(o11y.Config{Log: cfg})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recursePanic(20)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		var entry struct{ Stack string }
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry.Stack
+	}
+
+	assert.Equal(t, 21, strings.Count(panicStack(o11y.LogConfig{}), "o11y_test.recursePanic("))
+
+	stack := panicStack(o11y.LogConfig{CollapseStackFrames: true})
+	assert.Equal(t, 2, strings.Count(stack, "o11y_test.recursePanic("), stack)
+	assert.Contains(t, stack, " (x20)\n")
+}
+
+// TestStackHook 测试按级别附加堆栈，以及通过配置启用 error 级别的堆栈
+func TestStackHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Hook(o11y.StackHook(zerolog.ErrorLevel, nil))
+
+	logger.Error().Msg("failed")
+	var entry struct{ Stack string }
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Contains(t, entry.Stack, "log_test.go")
+	// 钩子自身的帧被默认规则过滤
+	assert.NotContains(t, entry.Stack, "github.com/oy3o/o11y.StackHook", entry.Stack)
+
+	// 低于阈值的级别以及无级别事件不附加堆栈
+	buf.Reset()
+	logger.Warn().Msg("slow")
+	logger.Log().Msg("plain")
+	assert.NotContains(t, buf.String(), `"stack"`)
+
+	// 通过配置启用
+	originalLogger := log.Logger
+	t.Cleanup(func() { log.Logger = originalLogger })
+	logFile := filepath.Join(t.TempDir(), "stack.log")
+	shutdown, err := o11y.Init(o11y.Config{
+		Enabled: true,
+		Log: o11y.LogConfig{
+			Level:           "info",
+			StackTraceLevel: "error",
+			EnableFile:      true,
+			FileRotation:    o11y.FileRotationConfig{Filename: logFile},
+		},
+	})
+	require.NoError(t, err)
+	log.Error().Msg("failed")
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"stack":"`)
+}
+
+// TestMaxFieldLength 测试超长字符串字段被截断并带有 _truncated 标记
+func TestMaxFieldLength(t *testing.T) {
+	originalLogger := log.Logger
+	t.Cleanup(func() { log.Logger = originalLogger })
+	logFile := filepath.Join(t.TempDir(), "truncate.log")
+	shutdown, err := o11y.Init(o11y.Config{
+		Enabled: true,
+		Log: o11y.LogConfig{
+			Level:          "info",
+			MaxFieldLength: 8,
+			EnableFile:     true,
+			FileRotation:   o11y.FileRotationConfig{Filename: logFile},
+		},
+	})
+	require.NoError(t, err)
+	log.Info().Str("body", "0123456789<>").Str("short", "ok").Int("n", 42).Msg("req")
+	log.Info().Str("short", "ok").Msg("ok")
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	var truncated, untouched map[string]any
+	for line := range strings.SplitSeq(strings.TrimSpace(string(content)), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		switch entry["message"] {
+		case "req":
+			truncated = entry
+		case "ok":
+			untouched = entry
+		}
+	}
+
+	require.NotNil(t, truncated)
+	assert.Equal(t, "01234567…", truncated["body"])
+	assert.Equal(t, "ok", truncated["short"])
+	assert.Equal(t, float64(42), truncated["n"])
+	assert.Equal(t, true, truncated["_truncated"])
+
+	// 没有超长字段的事件保持原样
+	require.NotNil(t, untouched)
+	assert.NotContains(t, untouched, "_truncated")
+}
@@ -0,0 +1,119 @@
+package o11y
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// truncatedFieldName is the field added to log events whose string values were truncated.
+const truncatedFieldName = "_truncated"
+
+// truncateEllipsis is appended to truncated string values.
+const truncateEllipsis = "…"
+
+// truncatingWriter shortens the string values of JSON log events longer than maxLen bytes
+// before passing them to the underlying writer, and marks such events with "_truncated": true.
+// Events that cannot hold such a value, or that are not valid JSON, are passed through untouched.
+type truncatingWriter struct {
+	w      zerolog.LevelWriter
+	maxLen int
+}
+
+// newTruncatingWriter wraps w, or returns it as is if maxLen is not positive.
+func newTruncatingWriter(w zerolog.LevelWriter, maxLen int) zerolog.LevelWriter {
+	if maxLen <= 0 {
+		return w
+	}
+	return &truncatingWriter{w: w, maxLen: maxLen}
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	return t.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (t *truncatingWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	// No value can exceed the limit if the whole event does not.
+	if len(p) <= t.maxLen {
+		return t.w.WriteLevel(level, p)
+	}
+	if out, ok := t.truncate(p); ok {
+		if _, err := t.w.WriteLevel(level, out); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return t.w.WriteLevel(level, p)
+}
+
+// truncate returns the event with its long string values shortened, and whether any was.
+func (t *truncatingWriter) truncate(p []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	var event map[string]any
+	if err := dec.Decode(&event); err != nil {
+		return nil, false
+	}
+
+	truncated := false
+	for key, value := range event {
+		// Stack traces are only useful whole.
+		if key == "stack" {
+			continue
+		}
+		if v, ok := t.truncateValue(value); ok {
+			event[key] = v
+			truncated = true
+		}
+	}
+	if !truncated {
+		return nil, false
+	}
+	event[truncatedFieldName] = true
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(event); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// truncateValue shortens value if it is a long string, or holds one in a nested object or array.
+func (t *truncatingWriter) truncateValue(value any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= t.maxLen {
+			return v, false
+		}
+		cut := t.maxLen
+		// Do not split a multi-byte character.
+		for cut > 0 && !utf8.RuneStart(v[cut]) {
+			cut--
+		}
+		return v[:cut] + truncateEllipsis, true
+	case map[string]any:
+		truncated := false
+		for key, item := range v {
+			if item, ok := t.truncateValue(item); ok {
+				v[key] = item
+				truncated = true
+			}
+		}
+		return v, truncated
+	case []any:
+		truncated := false
+		for i, item := range v {
+			if item, ok := t.truncateValue(item); ok {
+				v[i] = item
+				truncated = true
+			}
+		}
+		return v, truncated
+	default:
+		return v, false
+	}
+}
@@ -2,86 +2,340 @@ package o11y
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	mt "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 )
 
+// metricsServerAddr is the address the Prometheus metrics server is bound to, if it is running.
+var metricsServerAddr atomic.Pointer[string]
+
+// MetricsServerAddr returns the address the Prometheus metrics server listens on, e.g.
+// "[::]:41234", or an empty string if it is not running. With PrometheusAddr ":0" a free port
+// is picked, which keeps a test's metrics server from clashing with one already listening.
+// The address is process-wide: it belongs to the most recent Init that started a server, so
+// tests that read it must not initialize o11y in parallel.
+//
+//	shutdown, _ := o11y.Init(o11y.Config{Enabled: true, Metric: o11y.MetricConfig{
+//	    Enabled: true, Exporter: "prometheus", PrometheusAddr: "127.0.0.1:0",
+//	}})
+//	resp, err := http.Get("http://" + o11y.MetricsServerAddr() + "/metrics")
+func MetricsServerAddr() string {
+	if addr := metricsServerAddr.Load(); addr != nil {
+		return *addr
+	}
+	return ""
+}
+
 // setupMetrics initializes and configures the global MeterProvider based on the MetricConfig.
-// It sets up the appropriate metric reader (e.g., Prometheus) and makes the provider
+// It sets up a metric reader per configured exporter (e.g., Prometheus) and makes the provider
 // available globally for the application to create and record metrics.
 // It returns the configured provider and its corresponding shutdown function.
 func setupMetrics(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+	setMetricDefaults(cfg)
+	runOverhead.Store(cfg.Enabled && cfg.RecordRunOverhead)
+
 	// 1. Handle the Enabled switch. If disabled, install a no-op provider and return.
 	if !cfg.Enabled {
 		// A MeterProvider with no reader will effectively discard all metrics.
 		mp := mt.NewMeterProvider(mt.WithResource(res))
-		otel.SetMeterProvider(mp)
+		if !cfg.skipGlobals {
+			otel.SetMeterProvider(mp)
+		}
 		// Return a no-op shutdown function.
 		return mp, func(context.Context) error { return nil }, nil
 	}
 
-	// 2. Create the appropriate metric reader based on the configuration.
+	temporality, err := temporalitySelector(cfg.Temporality)
+	if err != nil {
+		return nil, nil, err
+	}
+	buckets, err := durationBuckets(cfg.DurationBuckets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Prometheus is pull-based and only supports cumulative temporality.
+	exporters := cfg.exporters()
+	if cfg.Temporality == "delta" && slices.Contains(exporters, "prometheus") {
+		return nil, nil, fmt.Errorf("metric exporter prometheus does not support delta temporality")
+	}
+
+	// 2. Create a metric reader for every configured exporter.
 	// The reader is the component that collects metrics and makes them available to an exporter.
-	var reader mt.Reader
-	var err error
+	// A reader that cannot be created is skipped, so one broken exporter does not disable the others.
+	var readers []mt.Reader
 	var serverShutdown ShutdownFunc = func(ctx context.Context) error { return nil }
+	var serverAddr string
+	var readerErrs error
+
+	for _, exporter := range exporters {
+		// The Prometheus endpoint is bound first, so an address in use skips the exporter
+		// instead of failing later in the background.
+		var addr string
+		var shutdownServer ShutdownFunc
+		if exporter == "prometheus" {
+			addr, shutdownServer, err = servePrometheusMetrics(cfg)
+			if err != nil {
+				err = fmt.Errorf("failed to start metrics server for exporter %s: %w", exporter, err)
+				log.Error().Err(err).Msg("Skipping metric exporter.")
+				readerErrs = errors.Join(readerErrs, err)
+				continue
+			}
+		}
+
+		reader, err := newMetricReader(exporter, cfg, temporality)
+		if err != nil {
+			if shutdownServer != nil {
+				_ = shutdownServer(context.Background())
+			}
+			err = fmt.Errorf("failed to create metric reader for exporter %s: %w", exporter, err)
+			log.Error().Err(err).Msg("Skipping metric exporter.")
+			readerErrs = errors.Join(readerErrs, err)
+			continue
+		}
+		readers = append(readers, reader)
+		if shutdownServer != nil {
+			serverShutdown, serverAddr = shutdownServer, addr
+		}
+	}
+	if len(readers) == 0 {
+		return nil, nil, readerErrs
+	}
 
-	switch cfg.Exporter {
+	// 3. Create the MeterProvider.
+	// It is configured with the shared resource, the readers and the duration buckets.
+	mpOpts := []mt.Option{
+		mt.WithResource(res),
+		mt.WithView(durationView(buckets)),
+	}
+	for _, reader := range readers {
+		mpOpts = append(mpOpts, mt.WithReader(reader))
+	}
+	mp := mt.NewMeterProvider(mpOpts...)
+
+	// 4. Set the global MeterProvider.
+	// This makes it accessible throughout the application via otel.GetMeterProvider().
+	if !cfg.skipGlobals {
+		otel.SetMeterProvider(mp)
+	}
+
+	// 5. Return the provider and its shutdown function.
+	// Shutting down the provider flushes and closes all of its readers.
+	// Publish the metrics server address for MetricsServerAddr until shutdown. Setups without
+	// a Prometheus server leave the address of a running one in place.
+	var published *string
+	if serverAddr != "" {
+		published = &serverAddr
+		metricsServerAddr.Store(published)
+	}
+	return mp, func(ctx context.Context) error {
+		if published != nil {
+			metricsServerAddr.CompareAndSwap(published, nil)
+		}
+		return errors.Join(unregisterObservables(), mp.Shutdown(ctx), serverShutdown(ctx))
+	}, nil
+}
+
+// runOverhead reports whether Run records o11y.run.overhead.duration, see MetricConfig.RecordRunOverhead.
+var runOverhead atomic.Bool
+
+// metricDefaults holds the attributes added to every metric recorded through the registry,
+// see MetricConfig.BaggageAttributes and MetricConfig.ServiceAttributes. It is nil when none are configured.
+var metricDefaults atomic.Pointer[metricDefaultsConfig]
+
+// metricDefaultsConfig is the effective configuration of the attributes added to every metric.
+type metricDefaultsConfig struct {
+	// baggageKeys are copied from the baggage, or set to baggageMissing if absent.
+	baggageKeys    []string
+	baggageMissing string
+
+	// attrs are added as is.
+	attrs []attribute.KeyValue
+}
+
+// setMetricDefaults publishes the default metric attributes of cfg to the metric recording functions.
+func setMetricDefaults(cfg MetricConfig) {
+	keys := validBaggageKeys(cfg.BaggageAttributes)
+	if len(keys) == 0 && len(cfg.serviceAttrs) == 0 {
+		metricDefaults.Store(nil)
+		return
+	}
+	metricDefaults.Store(&metricDefaultsConfig{baggageKeys: keys, baggageMissing: baggageDefault(cfg.BaggageAttributesDefault), attrs: cfg.serviceAttrs})
+}
+
+// withDefaultAttributes adds the configured default attributes, including those taken from the
+// baggage of ctx, to attrs; attrs win on key collision.
+func withDefaultAttributes(ctx context.Context, attrs []attribute.KeyValue) []attribute.KeyValue {
+	d := metricDefaults.Load()
+	if d == nil {
+		return attrs
+	}
+	merged := slices.Clip(attrs)
+	add := func(defaults []attribute.KeyValue) {
+		for _, kv := range defaults {
+			if !slices.ContainsFunc(attrs, func(a attribute.KeyValue) bool { return a.Key == kv.Key }) {
+				merged = append(merged, kv)
+			}
+		}
+	}
+	if len(d.baggageKeys) > 0 {
+		add(baggageAttributes(ctx, d.baggageKeys, d.baggageMissing))
+	}
+	add(d.attrs)
+	return merged
+}
+
+// newMetricReader creates the metric reader of the named exporter.
+func newMetricReader(exporter string, cfg MetricConfig, temporality mt.TemporalitySelector) (mt.Reader, error) {
+	switch exporter {
 	case "prometheus":
 		// This exporter makes metrics available on an HTTP endpoint for a Prometheus server to scrape.
 		log.Info().Msg("Initializing Prometheus metrics exporter.")
-
 		// prometheus.New() creates a reader that collects metrics and serves them via the promhttp.Handler.
-		reader, err = prometheus.New()
-		if err == nil {
-			// If the reader is created successfully, we must expose the HTTP endpoint.
-			// This is done in a separate goroutine to prevent blocking the main application startup.
-			serverShutdown = servePrometheusMetrics(cfg)
+		return prometheus.New(prometheusOptions(cfg)...)
+
+	case "otlp-grpc":
+		// This exporter periodically pushes metrics to an OpenTelemetry Collector.
+		log.Info().Msgf("Initializing OTLP gRPC metrics exporter with endpoint: %s", cfg.Endpoint)
+		exp, err := otlpmetricgrpc.New(context.Background(), otlpMetricOptions(cfg, temporality)...)
+		if err != nil {
+			return nil, err
 		}
+		// Surface export failures as metrics, like the span exporter.
+		return mt.NewPeriodicReader(newCountingMetricExporter(exp, exporter)), nil
 
 	default: // "none" or any other value
 		// A ManualReader is used when we want to enable the metrics API but not export the data.
 		// It requires manual collection, which we won't do, so it effectively discards metrics.
 		log.Info().Msg("Initializing no-op metrics exporter.")
-		reader = mt.NewManualReader()
+		return mt.NewManualReader(mt.WithTemporalitySelector(temporality)), nil
 	}
+}
 
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create metric reader for exporter %s: %w", cfg.Exporter, err)
+// countingMetricExporter decorates a metric Exporter to record its exporterHealth.
+type countingMetricExporter struct {
+	mt.Exporter
+	health exporterHealth
+}
+
+// newCountingMetricExporter wraps exporter, tagging its metrics with the exporter name.
+func newCountingMetricExporter(exporter mt.Exporter, name string) *countingMetricExporter {
+	return &countingMetricExporter{Exporter: exporter, health: newExporterHealth(name, "metrics")}
+}
+
+// Export exports the metrics and records the outcome.
+func (e *countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	e.health.record(ctx, err)
+	return err
+}
+
+// otlpMetricOptions returns the OTLP metric exporter options for the configuration.
+// Without an endpoint, the exporter falls back to the OTEL_EXPORTER_OTLP_* environment variables.
+func otlpMetricOptions(cfg MetricConfig, temporality mt.TemporalitySelector) []otlpmetricgrpc.Option {
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithTemporalitySelector(temporality)}
+	if cfg.Endpoint != "" {
+		target, dialer := otlpTarget(cfg.Endpoint)
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(target))
+		if dialer != nil {
+			opts = append(opts, otlpmetricgrpc.WithDialOption(dialer))
+		}
+		if cfg.OtlpInsecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+			log.Warn().Msg("OTLP metric exporter is using an insecure gRPC connection.")
+		}
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.Headers))
 	}
+	return opts
+}
 
-	// 3. Create the MeterProvider.
-	// It is configured with the shared resource and the selected reader.
-	mp := mt.NewMeterProvider(
-		mt.WithResource(res),
-		mt.WithReader(reader),
-	)
+// prometheusOptions returns the Prometheus exporter options for the configuration.
+func prometheusOptions(cfg MetricConfig) []prometheus.Option {
+	var opts []prometheus.Option
+	if cfg.PrometheusNamespace != "" {
+		opts = append(opts, prometheus.WithNamespace(cfg.PrometheusNamespace))
+	}
+	if cfg.PrometheusWithoutTargetInfo {
+		opts = append(opts, prometheus.WithoutTargetInfo())
+	}
+	if cfg.PrometheusWithoutScopeInfo {
+		opts = append(opts, prometheus.WithoutScopeInfo())
+	}
+	return opts
+}
 
-	// 4. Set the global MeterProvider.
-	// This makes it accessible throughout the application via otel.GetMeterProvider().
-	otel.SetMeterProvider(mp)
+// temporalitySelector returns the TemporalitySelector for the configured MetricConfig.Temporality.
+func temporalitySelector(temporality string) (mt.TemporalitySelector, error) {
+	switch temporality {
+	case "", "cumulative":
+		return mt.DefaultTemporalitySelector, nil
+	case "delta":
+		return deltaTemporalitySelector, nil
+	default:
+		return nil, fmt.Errorf("unsupported metric temporality %q", temporality)
+	}
+}
 
-	// 5. Return the provider and its shutdown function.
-	return mp, func(ctx context.Context) error {
-		err1 := mp.Shutdown(ctx)
-		err2 := serverShutdown(ctx)
-		if err1 != nil {
-			return err1
+// deltaTemporalitySelector uses delta temporality for counters and histograms.
+// Up-down counters stay cumulative, since their deltas are meaningless on their own.
+func deltaTemporalitySelector(kind mt.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case mt.InstrumentKindCounter, mt.InstrumentKindHistogram, mt.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+// DefaultDurationBuckets are the default bucket boundaries, in seconds, of the duration
+// histograms, following the OpenTelemetry semantic conventions for HTTP server durations.
+// The OpenTelemetry SDK defaults (0 to 10000) are meant for milliseconds and would put almost
+// every request in the first bucket. See MetricConfig.DurationBuckets.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// durationBuckets returns the configured bucket boundaries, or the defaults if none are set.
+func durationBuckets(buckets []float64) ([]float64, error) {
+	if len(buckets) == 0 {
+		return slices.Clone(DefaultDurationBuckets), nil
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil, fmt.Errorf("metric duration buckets must be strictly increasing, got %v", buckets)
 		}
-		return err2
-	}, nil
+	}
+	return slices.Clone(buckets), nil
+}
+
+// durationView applies the bucket boundaries to every histogram measured in seconds.
+func durationView(buckets []float64) mt.View {
+	return mt.NewView(
+		mt.Instrument{Kind: mt.InstrumentKindHistogram, Unit: "s"},
+		mt.Stream{Aggregation: mt.AggregationExplicitBucketHistogram{Boundaries: buckets}},
+	)
 }
 
 // servePrometheusMetrics starts a dedicated HTTP server to expose the /metrics endpoint.
-func servePrometheusMetrics(cfg MetricConfig) ShutdownFunc {
+// The listener is bound before it returns, so bind errors are reported and the endpoint is
+// ready to be scraped; requests are then served in the background. It returns the bound
+// address, which differs from PrometheusAddr when a free port is requested with port 0.
+func servePrometheusMetrics(cfg MetricConfig) (string, ShutdownFunc, error) {
 	// Use a new ServeMux to avoid interfering with the main application's router
 	// if it also uses the default ServeMux.
 	mux := http.NewServeMux()
@@ -92,14 +346,25 @@ func servePrometheusMetrics(cfg MetricConfig) ShutdownFunc {
 		Handler: mux,
 	}
 
-	log.Info().Str("path", cfg.PrometheusPath).Str("addr", cfg.PrometheusAddr).Msg("Prometheus metrics server starting.")
+	addr := cfg.PrometheusAddr
+	if addr == "" {
+		// Match http.Server, which listens on ":http" for an empty address.
+		addr = ":http"
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, err
+	}
+	boundAddr := lis.Addr().String()
+
+	log.Info().Str("path", cfg.PrometheusPath).Str("addr", boundAddr).Msg("Prometheus metrics server starting.")
 
-	// Start the server.
+	// Serve in the background so the main application startup is not blocked.
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal().Err(err).Msg("Prometheus metrics server failed.")
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Prometheus metrics server failed.")
 		}
 	}()
 
-	return server.Shutdown
+	return boundAddr, server.Shutdown, nil
 }
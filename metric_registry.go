@@ -2,6 +2,9 @@ package o11y
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"sync"
 	"sync/atomic"
 
@@ -15,12 +18,54 @@ import (
 // Using a struct allows us to store different instrument types (Counter, Histogram, etc.)
 // under a single map entry, providing type safety when we retrieve them.
 type MetricInstrument struct {
+	// Kind identifies which of the instrument fields below is set.
+	Kind InstrumentKind
+
 	Int64Counter       metric.Int64Counter
 	Float64Histogram   metric.Float64Histogram
 	Int64UpDownCounter metric.Int64UpDownCounter
+	Int64Histogram     metric.Int64Histogram
+
+	Int64ObservableUpDownCounter metric.Int64ObservableUpDownCounter
 	// NOTE: More instrument types like Gauge or UpDownCounter can be added here as needed.
 }
 
+// InstrumentKind is the type of a registered metric instrument.
+type InstrumentKind int
+
+const (
+	// InstrumentKindUnknown is the kind of metrics that are not registered.
+	InstrumentKindUnknown InstrumentKind = iota
+	// InstrumentKindCounter is an Int64Counter.
+	InstrumentKindCounter
+	// InstrumentKindHistogram is a Float64Histogram.
+	InstrumentKindHistogram
+	// InstrumentKindUpDownCounter is an Int64UpDownCounter.
+	InstrumentKindUpDownCounter
+	// InstrumentKindInt64Histogram is an Int64Histogram.
+	InstrumentKindInt64Histogram
+	// InstrumentKindObservableUpDownCounter is an Int64ObservableUpDownCounter.
+	InstrumentKindObservableUpDownCounter
+)
+
+// String returns the name of the instrument kind.
+func (k InstrumentKind) String() string {
+	switch k {
+	case InstrumentKindCounter:
+		return "Int64Counter"
+	case InstrumentKindHistogram:
+		return "Float64Histogram"
+	case InstrumentKindUpDownCounter:
+		return "Int64UpDownCounter"
+	case InstrumentKindInt64Histogram:
+		return "Int64Histogram"
+	case InstrumentKindObservableUpDownCounter:
+		return "Int64ObservableUpDownCounter"
+	default:
+		return "Unknown"
+	}
+}
+
 // registry stores all pre-registered standard metric instruments.
 // We use atomic.Value to store map[string]MetricInstrument to achieve lock-free reads.
 var (
@@ -36,8 +81,103 @@ var (
 	// Map key is the metric name. Value is *atomic.Int64.
 	// We use sync.Map for thread-safe concurrent access.
 	localValues = xsync.NewMap[string, *atomic.Int64]()
+
+	// localValuesFull records that the maxLocalValues bound was hit, so the warning is logged only once.
+	localValuesFull atomic.Bool
+)
+
+// disabledMetrics holds the names of the metrics muted with DisableMetric.
+var disabledMetrics = xsync.NewMap[string, struct{}]()
+
+// DisableMetric mutes the named metric at runtime: records to it are silently dropped,
+// including the in-process value, until EnableMetric is called. The instrument stays
+// registered. This is a kill-switch for expensive or misbehaving instruments.
+func DisableMetric(name string) {
+	disabledMetrics.Store(name, struct{}{})
+}
+
+// EnableMetric resumes recording on a metric muted with DisableMetric.
+func EnableMetric(name string) {
+	disabledMetrics.Delete(name)
+}
+
+// metricDisabled reports whether the named metric was muted with DisableMetric.
+func metricDisabled(name string) bool {
+	_, ok := disabledMetrics.Load(name)
+	return ok
+}
+
+// maxUnregisteredNames bounds the distinct metric_name values of o11y.metrics.unregistered.total.
+// Further unknown names are recorded as "other".
+const maxUnregisteredNames = 100
+
+// unregisteredNames holds the metric names already used as metric_name attribute values.
+var unregisteredNames = xsync.NewMap[string, struct{}]()
+
+// recordUnregistered reports a record targeting an unknown metric name, both as a debug log
+// and on the o11y.metrics.unregistered.total counter, so instrumentation bugs are visible
+// in production where debug logging is off.
+func recordUnregistered(ctx context.Context, reg map[string]MetricInstrument, name string) {
+	log.Debug().Str("metric_name", name).Msg("Metric not registered, skipping record")
+
+	const selfName = "o11y.metrics.unregistered.total"
+	instrument := reg[selfName]
+	if instrument.Int64Counter == nil {
+		return
+	}
+	instrument.Int64Counter.Add(ctx, 1, metric.WithAttributes(attribute.String("metric_name", unregisteredNameAttr(name))))
+	addLocalValue(selfName, 1)
+}
+
+// unregisteredNameAttr returns name, or "other" once maxUnregisteredNames distinct names were seen.
+func unregisteredNameAttr(name string) string {
+	if _, ok := unregisteredNames.Load(name); ok {
+		return name
+	}
+	if unregisteredNames.Size() >= maxUnregisteredNames {
+		return "other"
+	}
+	unregisteredNames.Store(name, struct{}{})
+	return name
+}
+
+// spansStarted and metricsRecorded count the spans started by Run and State.Span and the
+// successful metric records. They are plain atomics, exported through observable counters,
+// so that counting adds no metric record to every record.
+var (
+	spansStarted    atomic.Int64
+	metricsRecorded atomic.Int64
 )
 
+// registerSelfCounters exposes spansStarted and metricsRecorded as observable counters.
+func registerSelfCounters(meter metric.Meter) {
+	observe := func(v *atomic.Int64) metric.Int64Callback {
+		return func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(v.Load())
+			return nil
+		}
+	}
+	if _, err := meter.Int64ObservableCounter("o11y.spans.started.total",
+		metric.WithDescription("Counts the spans started by o11y.Run and State.Span."),
+		metric.WithUnit("{span}"),
+		metric.WithInt64Callback(observe(&spansStarted)),
+	); err != nil {
+		log.Error().Err(err).Msg("Failed to register o11y.spans.started.total")
+	}
+	if _, err := meter.Int64ObservableCounter("o11y.metrics.recorded.total",
+		metric.WithDescription("Counts the values recorded on registered metrics."),
+		metric.WithUnit("{record}"),
+		metric.WithInt64Callback(observe(&metricsRecorded)),
+	); err != nil {
+		log.Error().Err(err).Msg("Failed to register o11y.metrics.recorded.total")
+	}
+}
+
+// maxLocalValues bounds the number of metric names tracked in localValues.
+// Once reached, values for new names are no longer tracked locally (the OTel instruments
+// still record them) until entries are removed with ResetMetricValue or ResetAllMetricValues.
+const maxLocalValues = 10000
+
 // InitStandardMetrics creates and registers all standard metrics that the o11y library provides.
 // This function is called once by o11y.Init to populate the registry.
 // {Namespace}.{Subsystem}.{Target}.{Suffix}
@@ -45,15 +185,18 @@ func InitStandardMetrics(meter metric.Meter) {
 	registryOnce.Do(func() {
 		log.Debug().Msg("Initializing standard metrics registry...")
 
-		// Initialize with an empty map if nil
+		// Initialize with an empty map if nil, under the lock of concurrent registrations.
+		registryMu.Lock()
 		if registry.Load() == nil {
 			registry.Store(make(map[string]MetricInstrument))
 		}
+		registryMu.Unlock()
 
 		// --- HTTP Server Metrics ---
 		RegisterFloat64Histogram("http.server.request.duration", "Measures the duration of inbound HTTP requests.", "s")
 		RegisterInt64Counter("http.server.request.total", "Counts the total number of inbound HTTP requests.", "{request}")
 		RegisterInt64UpDownCounter("http.server.active_requests", "Measures the number of concurrent inbound HTTP requests that are currently in-flight.", "{request}")
+		RegisterInt64Counter("http.server.timeout.total", "Counts inbound HTTP requests cut off by TimeoutMiddleware.", "{request}")
 
 		// --- RPC/gRPC Metrics ---
 		// 注册 gRPC Panic 计数器
@@ -65,6 +208,19 @@ func InitStandardMetrics(meter metric.Meter) {
 		// --- Application Operation Metrics ---
 		RegisterFloat64Histogram("biz.operation.duration", "Measures the duration of a specific business logic operation.", "s")
 		RegisterInt64Counter("biz.operation.error.total", "Counts the total number of errors for a specific business logic operation.", "{error}")
+		RegisterInt64Counter("biz.operation.retry.total", "Counts the retries of business logic operations run with RunWithRetry.", "{retry}")
+		RegisterFloat64Histogram("biz.operation.queue_wait", "Measures the time operations run with RunQueued waited in their queue.", "s")
+
+		// --- Library Self Metrics ---
+		RegisterInt64Counter("o11y.metrics.unregistered.total", "Counts records targeting metric names that are not registered.", "{record}")
+		RegisterInt64UpDownCounter("o11y.goroutines.active", "Measures the number of goroutines launched by o11y helpers that are still running.", "{goroutine}")
+		// Microseconds rather than seconds, so the default buckets fit the expected values.
+		RegisterInt64Histogram("o11y.run.overhead.duration", "Measures the time spent in o11y.Run outside the wrapped function.", "us")
+		registerSelfCounters(meter)
+
+		// --- Telemetry Pipeline Metrics ---
+		RegisterInt64Counter("otlp.exporter.export.failures", "Counts failed exports to the OTLP collector.", "{failure}")
+		RegisterInt64UpDownCounter("otlp.exporter.connected", "Reports whether the last export to the OTLP collector succeeded (1) or failed (0).", "{connection}")
 
 		// --- Manual/Business Metrics ---
 		RegisterInt64Counter("cache.client.operation.total", "Counts cache hits and misses.", "{event}")
@@ -74,64 +230,195 @@ func InitStandardMetrics(meter metric.Meter) {
 }
 
 // RegisterInt64Counter creates and registers a new Int64Counter.
-// It is safe to call this concurrently after o11y.Init.
-func RegisterInt64Counter(name, description, unit string) {
-	if Meter == nil {
-		log.Error().Msg("o11y.Meter is nil. Call o11y.Init before registering metrics.")
-		return
+// Like the other Register functions, it is safe to call concurrently, including with o11y.Init,
+// and returns ErrNotInitialized if the metrics were not initialized yet.
+func RegisterInt64Counter(name, description, unit string) error {
+	meter, err := registrationMeter()
+	if err != nil {
+		return err
 	}
 
-	inst, err := Meter.Int64Counter(
+	inst, err := meter.Int64Counter(
 		name,
 		metric.WithDescription(description),
 		metric.WithUnit(unit),
 	)
 	if err != nil {
 		log.Error().Err(err).Str("name", name).Msg("Failed to create Int64Counter")
-		return
+		return fmt.Errorf("failed to create Int64Counter %s: %w", name, err)
 	}
 
-	register(name, MetricInstrument{Int64Counter: inst})
+	register(name, MetricInstrument{Kind: InstrumentKindCounter, Int64Counter: inst})
+	return nil
 }
 
 // RegisterFloat64Histogram creates and registers a new Float64Histogram.
-func RegisterFloat64Histogram(name, description, unit string) {
-	if Meter == nil {
-		log.Error().Msg("o11y.Meter is nil. Call o11y.Init before registering metrics.")
-		return
+func RegisterFloat64Histogram(name, description, unit string) error {
+	meter, err := registrationMeter()
+	if err != nil {
+		return err
 	}
 
-	inst, err := Meter.Float64Histogram(
+	inst, err := meter.Float64Histogram(
 		name,
 		metric.WithDescription(description),
 		metric.WithUnit(unit),
 	)
 	if err != nil {
 		log.Error().Err(err).Str("name", name).Msg("Failed to create Float64Histogram")
-		return
+		return fmt.Errorf("failed to create Float64Histogram %s: %w", name, err)
 	}
 
-	register(name, MetricInstrument{Float64Histogram: inst})
+	register(name, MetricInstrument{Kind: InstrumentKindHistogram, Float64Histogram: inst})
+	return nil
 }
 
 // RegisterInt64UpDownCounter creates and registers a new Int64UpDownCounter.
-func RegisterInt64UpDownCounter(name, description, unit string) {
-	if Meter == nil {
-		log.Error().Msg("o11y.Meter is nil. Call o11y.Init before registering metrics.")
-		return
+func RegisterInt64UpDownCounter(name, description, unit string) error {
+	meter, err := registrationMeter()
+	if err != nil {
+		return err
 	}
 
-	inst, err := Meter.Int64UpDownCounter(
+	inst, err := meter.Int64UpDownCounter(
 		name,
 		metric.WithDescription(description),
 		metric.WithUnit(unit),
 	)
 	if err != nil {
 		log.Error().Err(err).Str("name", name).Msg("Failed to create Int64UpDownCounter")
-		return
+		return fmt.Errorf("failed to create Int64UpDownCounter %s: %w", name, err)
 	}
 
-	register(name, MetricInstrument{Int64UpDownCounter: inst})
+	register(name, MetricInstrument{Kind: InstrumentKindUpDownCounter, Int64UpDownCounter: inst})
+	return nil
+}
+
+// RegisterInt64Histogram creates and registers a new Int64Histogram, for integer
+// distributions such as payload sizes or item counts.
+func RegisterInt64Histogram(name, description, unit string) error {
+	meter, err := registrationMeter()
+	if err != nil {
+		return err
+	}
+
+	inst, err := meter.Int64Histogram(
+		name,
+		metric.WithDescription(description),
+		metric.WithUnit(unit),
+	)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to create Int64Histogram")
+		return fmt.Errorf("failed to create Int64Histogram %s: %w", name, err)
+	}
+
+	register(name, MetricInstrument{Kind: InstrumentKindInt64Histogram, Int64Histogram: inst})
+	return nil
+}
+
+// observableRegistrations holds the callback registrations of the observable metrics,
+// by metric name, so they are unregistered when the metrics provider shuts down.
+var observableRegistrations = xsync.NewMap[string, metric.Registration]()
+
+// RegisterInt64ObservableUpDownCounter creates and registers an Int64ObservableUpDownCounter
+// reporting the value returned by callback at every collection. It suits signed point-in-time
+// values that a component already tracks, such as the size of a connection pool or the number
+// of queued items. The callback must be safe for concurrent use; a panic in it is logged and
+// the observation skipped. Registering a name again replaces the previous callback.
+// The callback is unregistered when o11y shuts down.
+//
+// Example:
+//
+//	o11y.RegisterInt64ObservableUpDownCounter("queue.items", "Items waiting in the queue.", "{item}",
+//	    func() int64 { return int64(q.Len()) })
+func RegisterInt64ObservableUpDownCounter(name, description, unit string, callback func() int64) error {
+	meter, err := registrationMeter()
+	if err != nil {
+		return err
+	}
+
+	inst, err := meter.Int64ObservableUpDownCounter(
+		name,
+		metric.WithDescription(description),
+		metric.WithUnit(unit),
+	)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to create Int64ObservableUpDownCounter")
+		return fmt.Errorf("failed to create Int64ObservableUpDownCounter %s: %w", name, err)
+	}
+
+	reg, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		if value, ok := observeSafely(name, callback); ok {
+			o.ObserveInt64(inst, value)
+		}
+		return nil
+	}, inst)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to register Int64ObservableUpDownCounter callback")
+		return fmt.Errorf("failed to register the callback of %s: %w", name, err)
+	}
+	if prev, loaded := observableRegistrations.LoadAndStore(name, reg); loaded {
+		_ = prev.Unregister()
+	}
+
+	register(name, MetricInstrument{Kind: InstrumentKindObservableUpDownCounter, Int64ObservableUpDownCounter: inst})
+	return nil
+}
+
+// observeSafely calls the callback of an observable metric, recovering from a panic so
+// it does not take down the collection of the other metrics.
+func observeSafely(name string, callback func() int64) (value int64, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("name", name).Interface("panic", r).Msg("Observable metric callback panicked")
+			ok = false
+		}
+	}()
+	return callback(), true
+}
+
+// unregisterObservables unregisters the callbacks of the observable metrics and removes them
+// from the registry, since their instruments belong to the meter provider being shut down.
+func unregisterObservables() error {
+	var errs error
+	var names []string
+	observableRegistrations.Range(func(name string, reg metric.Registration) bool {
+		if err := reg.Unregister(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to unregister callback of %s: %w", name, err))
+		}
+		observableRegistrations.Delete(name)
+		names = append(names, name)
+		return true
+	})
+	if len(names) == 0 {
+		return errs
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	newMap := maps.Clone(getRegistryMap())
+	for _, name := range names {
+		if newMap[name].Kind == InstrumentKindObservableUpDownCounter {
+			delete(newMap, name)
+		}
+	}
+	registry.Store(newMap)
+	return errs
+}
+
+// ErrNotInitialized is returned by the metric registration functions when called before Init.
+var ErrNotInitialized = errors.New("o11y: metrics are not initialized, call o11y.Init before registering metrics")
+
+// registrationMeter returns the meter set by Init, or ErrNotInitialized.
+func registrationMeter() (metric.Meter, error) {
+	meterMu.RLock()
+	meter := Meter
+	meterMu.RUnlock()
+	if meter == nil {
+		log.Error().Msg("o11y.Meter is nil. Call o11y.Init before registering metrics.")
+		return nil, ErrNotInitialized
+	}
+	return meter, nil
 }
 
 // register adds the instrument to the global registry using Copy-On-Write.
@@ -171,6 +458,7 @@ var (
 	addToIntCounterFunc          = addToIntCounterImpl
 	addToInt64UpDownCounterFunc  = addToInt64UpDownCounterImpl
 	recordInFloat64HistogramFunc = recordInFloat64HistogramImpl
+	recordInInt64HistogramFunc   = recordInInt64HistogramImpl
 )
 
 // AddToIntCounter finds a pre-registered Int64Counter and adds a value to it.
@@ -181,6 +469,10 @@ func AddToIntCounter(ctx context.Context, name string, value int64, attributes .
 
 // addToIntCounterImpl is the default implementation of AddToIntCounter.
 func addToIntCounterImpl(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+	if metricDisabled(name) {
+		return
+	}
+
 	reg := getRegistryMap()
 	if reg == nil {
 		return
@@ -188,7 +480,7 @@ func addToIntCounterImpl(ctx context.Context, name string, value int64, attribut
 
 	instrument, ok := reg[name]
 	if !ok {
-		log.Debug().Str("metric_name", name).Msg("Metric not registered, skipping record")
+		recordUnregistered(ctx, reg, name)
 		return
 	}
 	if instrument.Int64Counter == nil {
@@ -196,11 +488,11 @@ func addToIntCounterImpl(ctx context.Context, name string, value int64, attribut
 		return
 	}
 
-	instrument.Int64Counter.Add(ctx, value, metric.WithAttributes(attributes...))
+	instrument.Int64Counter.Add(ctx, value, metric.WithAttributes(withDefaultAttributes(ctx, attributes)...))
+	metricsRecorded.Add(1)
 
 	// Update local value for querying
-	val, _ := localValues.LoadOrStore(name, &atomic.Int64{})
-	val.Add(value)
+	addLocalValue(name, value)
 }
 
 // AddToInt64UpDownCounter finds a pre-registered Int64UpDownCounter and adds a value to it.
@@ -210,6 +502,10 @@ func AddToInt64UpDownCounter(ctx context.Context, name string, value int64, attr
 
 // addToInt64UpDownCounterImpl is the default implementation of AddToInt64UpDownCounter.
 func addToInt64UpDownCounterImpl(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+	if metricDisabled(name) {
+		return
+	}
+
 	reg := getRegistryMap()
 	if reg == nil {
 		return
@@ -217,7 +513,7 @@ func addToInt64UpDownCounterImpl(ctx context.Context, name string, value int64,
 
 	instrument, ok := reg[name]
 	if !ok {
-		log.Debug().Str("metric_name", name).Msg("Metric not registered, skipping record")
+		recordUnregistered(ctx, reg, name)
 		return
 	}
 	if instrument.Int64UpDownCounter == nil {
@@ -225,11 +521,11 @@ func addToInt64UpDownCounterImpl(ctx context.Context, name string, value int64,
 		return
 	}
 
-	instrument.Int64UpDownCounter.Add(ctx, value, metric.WithAttributes(attributes...))
+	instrument.Int64UpDownCounter.Add(ctx, value, metric.WithAttributes(withDefaultAttributes(ctx, attributes)...))
+	metricsRecorded.Add(1)
 
 	// Update local value for querying
-	val, _ := localValues.LoadOrStore(name, &atomic.Int64{})
-	val.Add(value)
+	addLocalValue(name, value)
 }
 
 // RecordInFloat64Histogram finds a pre-registered Float64Histogram and records a value.
@@ -239,6 +535,10 @@ func RecordInFloat64Histogram(ctx context.Context, name string, value float64, a
 
 // recordInFloat64HistogramImpl is the default implementation of RecordInFloat64Histogram.
 func recordInFloat64HistogramImpl(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+	if metricDisabled(name) {
+		return
+	}
+
 	reg := getRegistryMap()
 	if reg == nil {
 		return
@@ -246,7 +546,7 @@ func recordInFloat64HistogramImpl(ctx context.Context, name string, value float6
 
 	instrument, ok := reg[name]
 	if !ok {
-		log.Debug().Str("metric_name", name).Msg("Metric not registered, skipping record")
+		recordUnregistered(ctx, reg, name)
 		return
 	}
 	if instrument.Float64Histogram == nil {
@@ -254,7 +554,66 @@ func recordInFloat64HistogramImpl(ctx context.Context, name string, value float6
 		return
 	}
 
-	instrument.Float64Histogram.Record(ctx, value, metric.WithAttributes(attributes...))
+	instrument.Float64Histogram.Record(ctx, value, metric.WithAttributes(withDefaultAttributes(ctx, attributes)...))
+	metricsRecorded.Add(1)
+}
+
+// RecordInInt64Histogram finds a pre-registered Int64Histogram and records a value.
+func RecordInInt64Histogram(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+	recordInInt64HistogramFunc(ctx, name, value, attributes...)
+}
+
+// recordInInt64HistogramImpl is the default implementation of RecordInInt64Histogram.
+func recordInInt64HistogramImpl(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+	if metricDisabled(name) {
+		return
+	}
+
+	reg := getRegistryMap()
+	if reg == nil {
+		return
+	}
+
+	instrument, ok := reg[name]
+	if !ok {
+		recordUnregistered(ctx, reg, name)
+		return
+	}
+	if instrument.Int64Histogram == nil {
+		log.Warn().Str("metric_name", name).Msg("Metric type mismatch: expected Int64Histogram")
+		return
+	}
+
+	instrument.Int64Histogram.Record(ctx, value, metric.WithAttributes(withDefaultAttributes(ctx, attributes)...))
+	metricsRecorded.Add(1)
+}
+
+// Record records value on the pre-registered metric with the given name, choosing the
+// recording function from the kind of the registered instrument: counters and up-down
+// counters add the value and integer histograms record it, both truncated to an integer;
+// float histograms record it as is.
+// This spares callers from matching AddTo*/RecordIn* to the registration function.
+func Record(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+	switch kind := MetricKind(name); kind {
+	case InstrumentKindCounter:
+		AddToIntCounter(ctx, name, int64(value), attributes...)
+	case InstrumentKindUpDownCounter:
+		AddToInt64UpDownCounter(ctx, name, int64(value), attributes...)
+	case InstrumentKindHistogram:
+		RecordInFloat64Histogram(ctx, name, value, attributes...)
+	case InstrumentKindInt64Histogram:
+		RecordInInt64Histogram(ctx, name, int64(value), attributes...)
+	case InstrumentKindObservableUpDownCounter:
+		log.Warn().Str("metric_name", name).Msg("Observable metrics are reported by their callback, skipping record")
+	default:
+		recordUnregistered(ctx, getRegistryMap(), name)
+	}
+}
+
+// MetricKind returns the kind of the metric registered under name,
+// or InstrumentKindUnknown if there is none.
+func MetricKind(name string) InstrumentKind {
+	return getRegistryMap()[name].Kind
 }
 
 // resetMetricFuncs resets the metric recording functions to their default implementations.
@@ -263,10 +622,29 @@ func resetMetricFuncs() {
 	addToIntCounterFunc = addToIntCounterImpl
 	addToInt64UpDownCounterFunc = addToInt64UpDownCounterImpl
 	recordInFloat64HistogramFunc = recordInFloat64HistogramImpl
+	recordInInt64HistogramFunc = recordInInt64HistogramImpl
+}
+
+// addLocalValue adds value to the in-process total of the named metric, respecting maxLocalValues.
+func addLocalValue(name string, value int64) {
+	val, ok := localValues.Load(name)
+	if !ok {
+		if localValues.Size() >= maxLocalValues {
+			if !localValuesFull.Swap(true) {
+				log.Warn().Str("metric_name", name).Int("limit", maxLocalValues).
+					Msg("Too many metric names tracked locally, new names will not be reflected in GetMetricValue")
+			}
+			return
+		}
+		val, _ = localValues.LoadOrStore(name, &atomic.Int64{})
+	}
+	val.Add(value)
 }
 
 // GetMetricValue returns the current value of a registered counter.
 // This is useful for internal dashboards/APIs that need to display current stats.
+// The value is the total accumulated over the process lifetime (or since the last reset),
+// summed across all attribute sets; it is independent of the exporter's aggregation.
 func GetMetricValue(name string) int64 {
 	val, ok := localValues.Load(name)
 	if !ok {
@@ -274,3 +652,29 @@ func GetMetricValue(name string) int64 {
 	}
 	return val.Load()
 }
+
+// MetricSnapshot returns a copy of the in-process values of all tracked metrics, keyed by name,
+// as GetMetricValue would return them. It is suited to a lightweight internal status endpoint
+// that does not go through Prometheus. Values recorded concurrently may or may not be included.
+func MetricSnapshot() map[string]int64 {
+	snapshot := make(map[string]int64, localValues.Size())
+	localValues.Range(func(name string, val *atomic.Int64) bool {
+		snapshot[name] = val.Load()
+		return true
+	})
+	return snapshot
+}
+
+// ResetMetricValue removes the in-process value of the named metric, so GetMetricValue
+// returns 0 until it is recorded again. It does not affect exported metrics.
+// Use it when rotating dynamically-named metrics (e.g. per-tenant counters) to avoid a slow leak.
+func ResetMetricValue(name string) {
+	localValues.Delete(name)
+	localValuesFull.Store(false)
+}
+
+// ResetAllMetricValues removes all in-process metric values. It does not affect exported metrics.
+func ResetAllMetricValues() {
+	localValues.Clear()
+	localValuesFull.Store(false)
+}
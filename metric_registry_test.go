@@ -2,9 +2,16 @@ package o11y
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
+	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	mt "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestMetricRegistry_DynamicRegistration(t *testing.T) {
@@ -58,3 +65,292 @@ func TestMetricRegistry_TypeMismatch(t *testing.T) {
 		RecordInFloat64Histogram(context.Background(), name, 10.5)
 	})
 }
+
+func TestMetricRegistry_ResetMetricValue(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	defer ResetAllMetricValues()
+
+	RegisterInt64Counter("tenant.a.total", "desc", "1")
+	RegisterInt64Counter("tenant.b.total", "desc", "1")
+	AddToIntCounter(context.Background(), "tenant.a.total", 3)
+	AddToIntCounter(context.Background(), "tenant.b.total", 5)
+	assert.Equal(t, int64(3), GetMetricValue("tenant.a.total"))
+
+	ResetMetricValue("tenant.a.total")
+	assert.Equal(t, int64(0), GetMetricValue("tenant.a.total"))
+	assert.Equal(t, int64(5), GetMetricValue("tenant.b.total"))
+
+	AddToIntCounter(context.Background(), "tenant.a.total", 1)
+	assert.Equal(t, int64(1), GetMetricValue("tenant.a.total"))
+
+	ResetAllMetricValues()
+	assert.Equal(t, int64(0), GetMetricValue("tenant.a.total"))
+	assert.Equal(t, int64(0), GetMetricValue("tenant.b.total"))
+}
+
+func TestMetricRegistry_DisableMetric(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	defer ResetAllMetricValues()
+
+	name := "muted.total"
+	RegisterInt64Counter(name, "desc", "1")
+	defer EnableMetric(name)
+
+	AddToIntCounter(context.Background(), name, 1)
+	DisableMetric(name)
+	AddToIntCounter(context.Background(), name, 10)
+	assert.Equal(t, int64(1), GetMetricValue(name))
+	assert.Equal(t, InstrumentKindCounter, MetricKind(name), "muted metrics stay registered")
+
+	EnableMetric(name)
+	AddToIntCounter(context.Background(), name, 2)
+	assert.Equal(t, int64(3), GetMetricValue(name))
+}
+
+func TestMetricRegistry_MetricSnapshot(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	ResetAllMetricValues()
+	defer ResetAllMetricValues()
+
+	RegisterInt64Counter("snapshot.a.total", "desc", "1")
+	RegisterInt64UpDownCounter("snapshot.b.active", "desc", "1")
+	AddToIntCounter(context.Background(), "snapshot.a.total", 3)
+	AddToInt64UpDownCounter(context.Background(), "snapshot.b.active", 2)
+
+	snapshot := MetricSnapshot()
+	assert.Equal(t, map[string]int64{"snapshot.a.total": 3, "snapshot.b.active": 2}, snapshot)
+
+	// The snapshot is a copy.
+	AddToIntCounter(context.Background(), "snapshot.a.total", 1)
+	assert.Equal(t, int64(3), snapshot["snapshot.a.total"])
+}
+
+func TestMetricRegistry_MetricsRecorded(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	RegisterInt64Counter("recorded.a.total", "desc", "1")
+	RegisterFloat64Histogram("recorded.b.duration", "desc", "s")
+	recorded := metricsRecorded.Load()
+
+	AddToIntCounter(context.Background(), "recorded.a.total", 1)
+	RecordInFloat64Histogram(context.Background(), "recorded.b.duration", 0.5)
+	AddToIntCounter(context.Background(), "recorded.missing.total", 1)
+	assert.Equal(t, recorded+2, metricsRecorded.Load())
+}
+
+func TestMetricRegistry_LocalValuesBound(t *testing.T) {
+	ResetAllMetricValues()
+	defer ResetAllMetricValues()
+
+	for i := range maxLocalValues {
+		addLocalValue(fmt.Sprintf("bounded.%d", i), 1)
+	}
+	// Known names keep being tracked, new names are dropped.
+	addLocalValue("bounded.0", 1)
+	addLocalValue("bounded.overflow", 1)
+	assert.Equal(t, int64(2), GetMetricValue("bounded.0"))
+	assert.Equal(t, int64(0), GetMetricValue("bounded.overflow"))
+
+	// Freeing an entry makes room again.
+	ResetMetricValue("bounded.1")
+	addLocalValue("bounded.overflow", 1)
+	assert.Equal(t, int64(1), GetMetricValue("bounded.overflow"))
+}
+
+func TestMetricRegistry_Record(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	defer resetMetricFuncs()
+
+	RegisterInt64Counter("record.counter", "desc", "1")
+	RegisterInt64UpDownCounter("record.updown", "desc", "1")
+	RegisterFloat64Histogram("record.histogram", "desc", "s")
+	RegisterInt64Histogram("record.int_histogram", "desc", "By")
+	assert.Equal(t, InstrumentKindCounter, MetricKind("record.counter"))
+	assert.Equal(t, InstrumentKindUnknown, MetricKind("record.missing"))
+
+	var calls []string
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		calls = append(calls, fmt.Sprintf("counter %s %d", name, value))
+	}
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		calls = append(calls, fmt.Sprintf("updown %s %d", name, value))
+	}
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		calls = append(calls, fmt.Sprintf("histogram %s %g", name, value))
+	}
+	recordInInt64HistogramFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		calls = append(calls, fmt.Sprintf("int_histogram %s %d", name, value))
+	}
+
+	ctx := context.Background()
+	Record(ctx, "record.counter", 2)
+	Record(ctx, "record.updown", -1)
+	Record(ctx, "record.histogram", 0.25)
+	Record(ctx, "record.int_histogram", 512)
+	Record(ctx, "record.missing", 1)
+
+	assert.Equal(t, []string{
+		"counter record.counter 2",
+		"updown record.updown -1",
+		"histogram record.histogram 0.25",
+		"int_histogram record.int_histogram 512",
+	}, calls)
+}
+
+func TestMetricRegistry_Int64Histogram(t *testing.T) {
+	reader := mt.NewManualReader()
+	original := Meter
+	Meter = mt.NewMeterProvider(mt.WithReader(reader)).Meter("test")
+	t.Cleanup(func() { Meter = original })
+
+	RegisterInt64Histogram("payload.size", "desc", "By")
+	assert.Equal(t, InstrumentKindInt64Histogram, MetricKind("payload.size"))
+	assert.Equal(t, "Int64Histogram", InstrumentKindInt64Histogram.String())
+
+	s := State{ctx: context.Background()}
+	s.RecordIntHistogram("payload.size", 100)
+	s.RecordIntHistogram("payload.size", 300)
+	// A float histogram of the same name is a type mismatch and records nothing.
+	RecordInFloat64Histogram(context.Background(), "payload.size", 1.5)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	hist, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), hist.DataPoints[0].Count)
+	assert.Equal(t, int64(400), hist.DataPoints[0].Sum)
+}
+
+func TestMetricRegistry_ObservableUpDownCounter(t *testing.T) {
+	reader := mt.NewManualReader()
+	original := Meter
+	Meter = mt.NewMeterProvider(mt.WithReader(reader)).Meter("test")
+	t.Cleanup(func() { Meter = original })
+
+	queued := int64(5)
+	RegisterInt64ObservableUpDownCounter("queue.items", "desc", "{item}", func() int64 { return queued })
+	RegisterInt64ObservableUpDownCounter("queue.broken", "desc", "{item}", func() int64 { panic("boom") })
+	assert.Equal(t, InstrumentKindObservableUpDownCounter, MetricKind("queue.items"))
+
+	collect := func() map[string][]metricdata.DataPoint[int64] {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		points := map[string][]metricdata.DataPoint[int64]{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+					assert.False(t, sum.IsMonotonic)
+					points[m.Name] = sum.DataPoints
+				}
+			}
+		}
+		return points
+	}
+
+	points := collect()
+	require.Len(t, points["queue.items"], 1)
+	assert.Equal(t, int64(5), points["queue.items"][0].Value)
+	// A panicking callback is skipped without affecting the others.
+	assert.Empty(t, points["queue.broken"])
+
+	queued = -2
+	assert.Equal(t, int64(-2), collect()["queue.items"][0].Value)
+
+	// Shutdown unregisters the callbacks and removes them from the registry.
+	require.NoError(t, unregisterObservables())
+	assert.Equal(t, InstrumentKindUnknown, MetricKind("queue.items"))
+	assert.Empty(t, collect()["queue.items"])
+}
+
+func TestMetricRegistry_Unregistered(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	before := GetMetricValue("o11y.metrics.unregistered.total")
+	AddToIntCounter(context.Background(), "unregistered.counter", 1)
+	RecordInFloat64Histogram(context.Background(), "unregistered.histogram", 1)
+	Record(context.Background(), "unregistered.any", 1)
+	assert.Equal(t, before+3, GetMetricValue("o11y.metrics.unregistered.total"))
+}
+
+func TestUnregisteredNameAttr(t *testing.T) {
+	unregisteredNames.Clear()
+	defer unregisteredNames.Clear()
+
+	for i := range maxUnregisteredNames {
+		assert.Equal(t, fmt.Sprintf("unknown.%d", i), unregisteredNameAttr(fmt.Sprintf("unknown.%d", i)))
+	}
+	// Known names keep their value, new names are capped.
+	assert.Equal(t, "unknown.0", unregisteredNameAttr("unknown.0"))
+	assert.Equal(t, "other", unregisteredNameAttr("unknown.overflow"))
+}
+
+func TestMetricRegistry_NotInitialized(t *testing.T) {
+	original := Meter
+	Meter = nil
+	t.Cleanup(func() { Meter = original })
+
+	assert.ErrorIs(t, RegisterInt64Counter("early.total", "desc", "1"), ErrNotInitialized)
+	assert.ErrorIs(t, RegisterInt64ObservableUpDownCounter("early.items", "desc", "1", func() int64 { return 0 }), ErrNotInitialized)
+	assert.Equal(t, InstrumentKindUnknown, MetricKind("early.total"))
+}
+
+// TestMetricRegistry_RegisterDuringInit registers metrics while Init runs; run it with -race.
+func TestMetricRegistry_RegisterDuringInit(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			for j := range 50 {
+				// Registrations racing Init either succeed or report it is not done yet.
+				err := RegisterInt64Counter(fmt.Sprintf("race.%d.%d.total", i, j), "desc", "1")
+				if err != nil {
+					assert.ErrorIs(t, err, ErrNotInitialized)
+				}
+			}
+		})
+	}
+	shutdown, err := Init(cfg)
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+	wg.Wait()
+
+	require.NoError(t, RegisterInt64Counter("race.after.total", "desc", "1"))
+	assert.Equal(t, InstrumentKindCounter, MetricKind("race.after.total"))
+	assert.Equal(t, InstrumentKindHistogram, MetricKind("biz.operation.duration"))
+}
+
+func TestLocalValuesCollector(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	ResetAllMetricValues()
+	defer ResetAllMetricValues()
+
+	RegisterInt64Counter("collector.a.total", "desc", "1")
+	AddToIntCounter(context.Background(), "collector.a.total", 3)
+
+	reg := prom.NewPedanticRegistry()
+	require.NoError(t, reg.Register(NewLocalValuesCollector()))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "o11y_local_value", families[0].GetName())
+	require.Len(t, families[0].Metric, 1)
+	m := families[0].Metric[0]
+	assert.Equal(t, "collector.a.total", m.Label[0].GetValue())
+	assert.Equal(t, 3.0, m.Gauge.GetValue())
+}
@@ -0,0 +1,430 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	mt "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTemporalitySelector(t *testing.T) {
+	cumulative, err := temporalitySelector("")
+	assert.NoError(t, err)
+	assert.Equal(t, metricdata.CumulativeTemporality, cumulative(mt.InstrumentKindCounter))
+
+	delta, err := temporalitySelector("delta")
+	assert.NoError(t, err)
+	assert.Equal(t, metricdata.DeltaTemporality, delta(mt.InstrumentKindCounter))
+	assert.Equal(t, metricdata.DeltaTemporality, delta(mt.InstrumentKindHistogram))
+	assert.Equal(t, metricdata.CumulativeTemporality, delta(mt.InstrumentKindUpDownCounter))
+
+	_, err = temporalitySelector("weekly")
+	assert.Error(t, err)
+}
+
+func TestSetupMetrics_Temporality(t *testing.T) {
+	// Prometheus is pull-based and cumulative only.
+	_, _, err := setupMetrics(MetricConfig{Enabled: true, Exporter: "prometheus", Temporality: "delta"}, resource.Default())
+	assert.Error(t, err)
+
+	_, _, err = setupMetrics(MetricConfig{Enabled: true, Exporter: "none", Temporality: "weekly"}, resource.Default())
+	assert.Error(t, err)
+}
+
+// gatherPrometheusNames records a counter through an exporter built from cfg and returns the exposed metric names.
+func gatherPrometheusNames(t *testing.T, cfg MetricConfig) []string {
+	t.Helper()
+	reg := prom.NewRegistry()
+	exporter, err := prometheus.New(append(prometheusOptions(cfg), prometheus.WithRegisterer(reg))...)
+	assert.NoError(t, err)
+
+	mp := mt.NewMeterProvider(mt.WithReader(exporter))
+	defer mp.Shutdown(context.Background())
+	counter, err := mp.Meter("test").Int64Counter("orders.total")
+	assert.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	return names
+}
+
+func TestPrometheusOptions_Namespace(t *testing.T) {
+	assert.Contains(t, gatherPrometheusNames(t, MetricConfig{}), "orders_total")
+	assert.Contains(t, gatherPrometheusNames(t, MetricConfig{PrometheusNamespace: "myapp"}), "myapp_orders_total")
+}
+
+func TestPrometheusOptions_TargetAndScopeInfo(t *testing.T) {
+	reg := prom.NewRegistry()
+	cfg := MetricConfig{PrometheusWithoutTargetInfo: true, PrometheusWithoutScopeInfo: true}
+	exporter, err := prometheus.New(append(prometheusOptions(cfg), prometheus.WithRegisterer(reg))...)
+	assert.NoError(t, err)
+
+	mp := mt.NewMeterProvider(mt.WithReader(exporter), mt.WithResource(resource.Default()))
+	defer mp.Shutdown(context.Background())
+	counter, err := mp.Meter("test").Int64Counter("orders.total")
+	assert.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		assert.NotEqual(t, "target_info", family.GetName())
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				assert.NotContains(t, label.GetName(), "otel_scope_")
+			}
+		}
+	}
+
+	// By default, both are exposed.
+	assert.Contains(t, gatherPrometheusNames(t, MetricConfig{}), "target_info")
+}
+
+func TestDurationBuckets(t *testing.T) {
+	buckets, err := durationBuckets(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultDurationBuckets, buckets)
+
+	buckets, err = durationBuckets([]float64{0.1, 1, 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 1, 10}, buckets)
+
+	_, err = durationBuckets([]float64{1, 0.5})
+	assert.Error(t, err)
+	_, _, err = setupMetrics(MetricConfig{Enabled: true, Exporter: "none", DurationBuckets: []float64{1, 1}}, resource.Default())
+	assert.Error(t, err)
+}
+
+func TestDurationView(t *testing.T) {
+	reader := mt.NewManualReader()
+	mp := mt.NewMeterProvider(mt.WithReader(reader), mt.WithView(durationView([]float64{0.1, 1})))
+	defer mp.Shutdown(context.Background())
+	meter := mp.Meter("test")
+
+	seconds, err := meter.Float64Histogram("request.duration", metric.WithUnit("s"))
+	assert.NoError(t, err)
+	seconds.Record(context.Background(), 0.2)
+	sizes, err := meter.Float64Histogram("request.size", metric.WithUnit("By"))
+	assert.NoError(t, err)
+	sizes.Record(context.Background(), 200)
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	bounds := map[string][]float64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		bounds[m.Name] = m.Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds
+	}
+	assert.Equal(t, []float64{0.1, 1}, bounds["request.duration"])
+	assert.NotEqual(t, []float64{0.1, 1}, bounds["request.size"], "only durations use the view")
+}
+
+func TestMetricConfig_Exporters(t *testing.T) {
+	assert.Equal(t, []string{"none"}, MetricConfig{}.exporters())
+	assert.Equal(t, []string{"none"}, MetricConfig{Exporter: "none"}.exporters())
+	assert.Equal(t, []string{"prometheus", "otlp-grpc"},
+		MetricConfig{Exporter: "prometheus", Exporters: []string{"none", "otlp-grpc", "prometheus"}}.exporters())
+}
+
+// fakeMetricsCollector is an OTLP metrics service that reports every received request.
+type fakeMetricsCollector struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+	received chan *collectormetrics.ExportMetricsServiceRequest
+	// md holds the metadata of the last request.
+	md metadata.MD
+}
+
+func (c *fakeMetricsCollector) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	c.md, _ = metadata.FromIncomingContext(ctx)
+	c.received <- req
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+// TestSetupMetrics_MultipleExporters verifies that metrics are both scraped and pushed when two exporters are set.
+func TestSetupMetrics_MultipleExporters(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	collector := &fakeMetricsCollector{received: make(chan *collectormetrics.ExportMetricsServiceRequest, 1)}
+	srv := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(srv, collector)
+	go srv.Serve(lis)
+	defer srv.Stop()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://"+lis.Addr().String())
+
+	// Reserve a free port for the Prometheus endpoint.
+	promLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	promAddr := promLis.Addr().String()
+	promLis.Close()
+
+	cfg := MetricConfig{
+		Enabled:        true,
+		Exporter:       "prometheus",
+		Exporters:      []string{"otlp-grpc"},
+		PrometheusAddr: promAddr,
+		PrometheusPath: "/metrics",
+	}
+	mp, shutdown, err := setupMetrics(cfg, resource.Default())
+	require.NoError(t, err)
+
+	counter, err := mp.Meter("test").Int64Counter("migration.total")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	// Scraped by Prometheus...
+	var body []byte
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + promAddr + "/metrics")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, string(body), "migration_total")
+
+	// ...and pushed over OTLP when the provider flushes on shutdown.
+	assert.NoError(t, shutdown(context.Background()))
+	select {
+	case req := <-collector.received:
+		var names []string
+		for _, sm := range req.GetResourceMetrics()[0].GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				names = append(names, m.GetName())
+			}
+		}
+		assert.Contains(t, names, "migration.total")
+	default:
+		t.Fatal("collector did not receive any metrics")
+	}
+}
+
+// TestSetupMetrics_OTLPEndpoint verifies that the metric exporter targets its own endpoint with
+// the configured headers and temporality.
+func TestSetupMetrics_OTLPEndpoint(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	collector := &fakeMetricsCollector{received: make(chan *collectormetrics.ExportMetricsServiceRequest, 1)}
+	srv := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(srv, collector)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cfg := Config{
+		Trace: TraceConfig{Endpoint: "traces.invalid:4317"},
+		Metric: MetricConfig{
+			Enabled:      true,
+			Exporter:     "otlp-grpc",
+			Endpoint:     lis.Addr().String(),
+			OtlpInsecure: true,
+			Headers:      map[string]string{"x-api-key": "secret"},
+			Temporality:  "delta",
+		},
+	}
+	mp, shutdown, err := setupMetrics(cfg.metricConfig(), resource.Default())
+	require.NoError(t, err)
+
+	counter, err := mp.Meter("test").Int64Counter("split.total")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	assert.NoError(t, shutdown(context.Background()))
+	select {
+	case req := <-collector.received:
+		assert.Equal(t, []string{"secret"}, collector.md.Get("x-api-key"))
+		var sum *metricspb.Sum
+		for _, sm := range req.ResourceMetrics[0].ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "split.total" {
+					sum = m.GetSum()
+				}
+			}
+		}
+		require.NotNil(t, sum)
+		assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, sum.AggregationTemporality)
+	default:
+		t.Fatal("collector did not receive any metrics")
+	}
+}
+
+func TestConfig_MetricConfig(t *testing.T) {
+	trace := TraceConfig{Endpoint: "collector:4317", OtlpInsecure: true}
+
+	// Falls back to the trace collector.
+	m := Config{Trace: trace}.metricConfig()
+	assert.Equal(t, "collector:4317", m.Endpoint)
+	assert.True(t, m.OtlpInsecure)
+
+	// A dedicated metric collector keeps its own settings.
+	m = Config{Trace: trace, Metric: MetricConfig{Endpoint: "metrics:4317"}}.metricConfig()
+	assert.Equal(t, "metrics:4317", m.Endpoint)
+	assert.False(t, m.OtlpInsecure)
+}
+
+func TestMetricConfig_ServiceAttributes(t *testing.T) {
+	cfg := Config{Service: "checkout", Environment: "production"}
+	assert.Empty(t, cfg.metricConfig().serviceAttrs)
+
+	reader := mt.NewManualReader()
+	original := Meter
+	Meter = mt.NewMeterProvider(mt.WithReader(reader)).Meter("test")
+	t.Cleanup(func() {
+		Meter = original
+		metricDefaults.Store(nil)
+	})
+	cfg.Metric.ServiceAttributes = true
+	setMetricDefaults(cfg.metricConfig())
+	require.NoError(t, RegisterInt64Counter("orders.total", "desc", "1"))
+
+	AddToIntCounter(context.Background(), "orders.total", 1)
+	// Explicit attributes win.
+	AddToIntCounter(context.Background(), "orders.total", 1, attribute.String("deployment.environment.name", "canary"))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.Len(t, sum.DataPoints, 2)
+	var environments []string
+	for _, dp := range sum.DataPoints {
+		service, _ := dp.Attributes.Value("service.name")
+		assert.Equal(t, "checkout", service.AsString())
+		// The empty version is left out.
+		assert.False(t, dp.Attributes.HasValue("service.version"))
+		env, _ := dp.Attributes.Value("deployment.environment.name")
+		environments = append(environments, env.AsString())
+	}
+	assert.ElementsMatch(t, []string{"production", "canary"}, environments)
+}
+
+// TestServePrometheusMetrics verifies that the endpoint is ready on return and that bind errors are reported.
+func TestServePrometheusMetrics(t *testing.T) {
+	cfg := MetricConfig{PrometheusAddr: "127.0.0.1:0", PrometheusPath: "/metrics"}
+	addr, shutdown, err := servePrometheusMetrics(cfg)
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+	assert.NotEqual(t, "127.0.0.1:0", addr)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The address is now in use.
+	_, _, err = servePrometheusMetrics(MetricConfig{PrometheusAddr: addr, PrometheusPath: "/metrics"})
+	assert.Error(t, err)
+
+	_, _, err = setupMetrics(MetricConfig{Enabled: true, Exporter: "prometheus", PrometheusAddr: addr, PrometheusPath: "/metrics"}, resource.Default())
+	assert.ErrorContains(t, err, "failed to start metrics server")
+}
+
+// TestMetricsServerAddr verifies that a free port can be requested and its address retrieved.
+func TestMetricsServerAddr(t *testing.T) {
+	shutdown, err := Init(Config{Enabled: true, Metric: MetricConfig{
+		Enabled:        true,
+		Exporter:       "prometheus",
+		PrometheusAddr: "127.0.0.1:0",
+	}})
+	require.NoError(t, err)
+
+	addr := MetricsServerAddr()
+	require.NotEmpty(t, addr)
+	assert.NotEqual(t, "127.0.0.1:0", addr)
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A setup without a Prometheus server leaves the address of the running one in place.
+	_, otherShutdown, err := setupMetrics(MetricConfig{Enabled: true, Exporter: "none"}, resource.Default())
+	require.NoError(t, err)
+	assert.Equal(t, addr, MetricsServerAddr())
+	require.NoError(t, otherShutdown(context.Background()))
+	assert.Equal(t, addr, MetricsServerAddr())
+
+	require.NoError(t, shutdown(context.Background()))
+	assert.Empty(t, MetricsServerAddr())
+}
+
+// failingMetricExporter is a metric exporter whose exports fail with err, if set.
+type failingMetricExporter struct {
+	err error
+}
+
+func (e *failingMetricExporter) Temporality(k mt.InstrumentKind) metricdata.Temporality {
+	return mt.DefaultTemporalitySelector(k)
+}
+
+func (e *failingMetricExporter) Aggregation(k mt.InstrumentKind) mt.Aggregation {
+	return mt.DefaultAggregationSelector(k)
+}
+
+func (e *failingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.err
+}
+
+func (e *failingMetricExporter) ForceFlush(ctx context.Context) error { return nil }
+func (e *failingMetricExporter) Shutdown(ctx context.Context) error   { return nil }
+
+// TestCountingMetricExporter verifies that failed metric pushes are counted like span exports.
+func TestCountingMetricExporter(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	var failures int64
+	var failureAttrs []attribute.KeyValue
+	var connected []int64
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "otlp.exporter.export.failures" {
+			failures += value
+			failureAttrs = attributes
+		}
+	}
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "otlp.exporter.connected" {
+			connected = append(connected, value)
+		}
+	}
+
+	inner := &failingMetricExporter{err: errors.New("connection refused")}
+	mp := mt.NewMeterProvider(mt.WithReader(mt.NewPeriodicReader(newCountingMetricExporter(inner, "otlp-grpc"))))
+	defer mp.Shutdown(context.Background())
+	counter, err := mp.Meter("test").Int64Counter("orders.total")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	ctx := context.Background()
+	assert.Error(t, mp.ForceFlush(ctx))
+	inner.err = nil
+	assert.NoError(t, mp.ForceFlush(ctx))
+	inner.err = errors.New("unavailable")
+	assert.Error(t, mp.ForceFlush(ctx))
+
+	assert.Equal(t, int64(2), failures)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("exporter", "otlp-grpc"),
+		attribute.String("signal", "metrics"),
+	}, failureAttrs)
+	assert.Equal(t, []int64{1, -1}, connected)
+}
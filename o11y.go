@@ -2,6 +2,8 @@ package o11y
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -19,8 +21,34 @@ var (
 	Tracer trace.Tracer
 	// Meter is the application-wide meter, initialized by Init.
 	Meter metric.Meter
+
+	// meterMu guards the writes of Meter by Init against its reads by the metric
+	// registration functions, which may run concurrently with Init.
+	meterMu sync.RWMutex
+
+	// globalProvider is the Provider created by the last Init, used by OnShutdown.
+	globalProvider atomic.Pointer[Provider]
 )
 
+// OnShutdown registers fn to run when the ShutdownFunc returned by Init is called,
+// before the providers close. See Provider.OnShutdown. It must be called after Init;
+// otherwise fn is ignored and a warning is logged.
+//
+// Example:
+//
+//	start := time.Now()
+//	o11y.OnShutdown(func(ctx context.Context) {
+//	    o11y.RecordInFloat64Histogram(ctx, "process.uptime", time.Since(start).Seconds())
+//	})
+func OnShutdown(fn func(ctx context.Context)) {
+	p := globalProvider.Load()
+	if p == nil {
+		log.Warn().Msg("o11y.OnShutdown called before o11y.Init, ignoring the hook.")
+		return
+	}
+	p.OnShutdown(fn)
+}
+
 // GetTraceID extracts the TraceID of the OpenTelemetry from the Context.
 // If there is no valid Span in the current Context, it returns an empty string.
 func GetTraceID(ctx context.Context) string {
@@ -53,16 +81,22 @@ func initialization(
 	}
 
 	Tracer = p.Tracer
+	// Set before InitStandardMetrics, which registers on it.
+	meterMu.Lock()
 	Meter = p.Meter
+	meterMu.Unlock()
 	log.Logger = p.Logger
+	globalProvider.Store(p)
 
 	if cfg.Metric.Enabled {
 		// Initialize our pre-defined, standard metrics.
 		InitStandardMetrics(Meter)
 
-		// Start collecting Go runtime metrics.
-		if err := StartRuntimeMetrics(); err != nil {
-			log.Warn().Err(err).Msg("Could not start runtime metrics collection, but continuing initialization.")
+		// Start collecting Go runtime metrics unless disabled.
+		if cfg.Metric.runtimeMetricsEnabled() {
+			if err := StartRuntimeMetrics(); err != nil {
+				log.Warn().Err(err).Msg("Could not start runtime metrics collection, but continuing initialization.")
+			}
 		}
 
 		// Start collecting host metrics if enabled.
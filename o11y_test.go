@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
 	noopt "go.opentelemetry.io/otel/trace/noop"
 )
@@ -76,6 +80,58 @@ func TestInitHostMetrics(t *testing.T) {
 	}
 }
 
+// initRuntimeMetrics verifies that runtime metrics default to enabled and can be turned off.
+func TestInitRuntimeMetrics(t *testing.T) {
+	disabled, enabled := false, true
+	tests := []struct {
+		name                 string
+		enableRuntimeMetrics *bool
+		expectLog            bool
+	}{
+		{name: "Runtime metrics unset", enableRuntimeMetrics: nil, expectLog: true},
+		{name: "Runtime metrics enabled", enableRuntimeMetrics: &enabled, expectLog: true},
+		{name: "Runtime metrics disabled", enableRuntimeMetrics: &disabled, expectLog: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuffer bytes.Buffer
+			mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+				return zerolog.New(&logBuffer), func(ctx context.Context) error { return nil }
+			}
+			mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+				return noopt.NewTracerProvider(), func(ctx context.Context) error { return nil }, nil
+			}
+			mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+				return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+			}
+
+			cfg := Config{
+				Enabled: true,
+				Service: "test-service",
+				Log:     LogConfig{Level: "info"},
+				Metric: MetricConfig{
+					Enabled:              true,
+					EnableRuntimeMetrics: tt.enableRuntimeMetrics,
+					Exporter:             "none",
+				},
+			}
+
+			shutdown, _ := initialization(cfg, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+			defer func() {
+				assert.NoError(t, shutdown(context.Background()))
+			}()
+
+			logOutput := logBuffer.String()
+			if tt.expectLog {
+				assert.Contains(t, logOutput, "Initializing Go runtime metrics collection.")
+			} else {
+				assert.NotContains(t, logOutput, "Initializing Go runtime metrics collection.")
+			}
+		})
+	}
+}
+
 // initDisabledGlobally verifies that nothing is initialized when o11y is globally disabled.
 func TestInitDisabledGlobally(t *testing.T) {
 	var logBuffer bytes.Buffer
@@ -190,3 +246,256 @@ func TestInitStandardMetrics(t *testing.T) {
 	assert.Contains(t, logOutput, "Initializing Go runtime metrics collection.", "Expected runtime metrics initialization log")
 	assert.NotContains(t, logOutput, "Initializing host metrics collection.", "Did not expect host metrics log")
 }
+
+// TestNew_InstrumentationVersion verifies that the tracer is created with the configured scope version.
+func TestNew_InstrumentationVersion(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+		return zerolog.Nop(), func(ctx context.Context) error { return nil }
+	}
+	mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+		return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)), func(ctx context.Context) error { return nil }, nil
+	}
+	mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+		return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+
+	p, err := New(Config{
+		Enabled:                true,
+		InstrumentationScope:   "o11y.test",
+		InstrumentationVersion: "v9.9.9",
+	}, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	assert.NoError(t, err)
+	defer p.Shutdown(context.Background())
+
+	_, span := p.Tracer.Start(context.Background(), "versioned")
+	span.End()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "o11y.test", spans[0].InstrumentationScope().Name)
+	assert.Equal(t, "v9.9.9", spans[0].InstrumentationScope().Version)
+}
+
+// TestNew_ResourceAttributes verifies that custom resource attributes are added without overriding the service identity.
+func TestNew_ResourceAttributes(t *testing.T) {
+	var got *resource.Resource
+	mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+		return zerolog.Nop(), func(ctx context.Context) error { return nil }
+	}
+	mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+		got = res
+		return noopt.NewTracerProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+	mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+		return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+
+	p, err := New(Config{
+		Enabled: true,
+		Service: "test-service",
+		ResourceAttributes: map[string]string{
+			"deployment.canary": "true",
+			"service.name":      "spoofed",
+		},
+	}, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	assert.NoError(t, err)
+	defer p.Shutdown(context.Background())
+
+	attrs := got.Set()
+	canary, _ := attrs.Value("deployment.canary")
+	assert.Equal(t, "true", canary.AsString())
+	service, _ := attrs.Value("service.name")
+	assert.Equal(t, "test-service", service.AsString())
+}
+
+// TestInitConfigSummary verifies that Init logs a summary of the effective configuration.
+func TestInitConfigSummary(t *testing.T) {
+	var logBuffer bytes.Buffer
+	mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+		return zerolog.New(&logBuffer), func(ctx context.Context) error { return nil }
+	}
+	mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+		return noopt.NewTracerProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+	mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+		return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+
+	cfg := Config{
+		Enabled:     true,
+		Service:     "test-service",
+		Version:     "1.0.0",
+		Environment: "test",
+		Trace: TraceConfig{
+			Enabled:     true,
+			Exporter:    "otlp-grpc",
+			Endpoint:    "collector:4317",
+			SampleRatio: 1.5,
+		},
+		Metric: MetricConfig{
+			Enabled:  true,
+			Exporter: "prometheus",
+		},
+	}
+
+	shutdown, _ := initialization(cfg, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	defer func() {
+		assert.NoError(t, shutdown(context.Background()))
+	}()
+
+	logOutput := logBuffer.String()
+	assert.Contains(t, logOutput, "o11y initialized with effective configuration.")
+	assert.Contains(t, logOutput, `"service":"test-service"`)
+	assert.Contains(t, logOutput, `"trace_exporter":"otlp-grpc"`)
+	assert.Contains(t, logOutput, `"trace_endpoint":"collector:4317"`)
+	// The summary reports the clamped ratio the sampler uses.
+	assert.Contains(t, logOutput, `"trace_sample_ratio":1,`)
+	assert.NotContains(t, logOutput, `"trace_sample_ratio":1.5`)
+	assert.Contains(t, logOutput, `"metric_addr":":2222/metrics"`)
+}
+
+// TestDevConfig verifies that the development preset initializes and that explicit values win.
+func TestDevConfig(t *testing.T) {
+	cfg := DevConfig("test-service")
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.True(t, cfg.Log.EnableConsole)
+	assert.Equal(t, "stdout", cfg.Trace.Exporter)
+	assert.Equal(t, 1.0, cfg.Trace.SampleRatio)
+
+	prevLevel := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(prevLevel) })
+	cfg.Log.Level = "warn"
+	shutdown, err := Init(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+// TestInitSetGlobalsDisabled verifies that the global OpenTelemetry providers are left untouched.
+func TestInitSetGlobalsDisabled(t *testing.T) {
+	tp, mp, propagator := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+
+	setGlobals := false
+	sr := tracetest.NewSpanRecorder()
+	shutdown, err := Init(Config{
+		Enabled:    true,
+		SetGlobals: &setGlobals,
+		Trace:      TraceConfig{Enabled: true, Exporter: "none", SampleRatio: 1, SpanProcessors: []sdktrace.SpanProcessor{sr}},
+		Metric:     MetricConfig{Enabled: true, Exporter: "none"},
+	})
+	assert.NoError(t, err)
+	defer shutdown(context.Background())
+
+	assert.Same(t, tp, otel.GetTracerProvider())
+	assert.Same(t, mp, otel.GetMeterProvider())
+	assert.Equal(t, propagator, otel.GetTextMapPropagator())
+
+	// The o11y providers are still used by Run.
+	_ = Run(context.Background(), "local", func(ctx context.Context, s State) error { return nil })
+	assert.Len(t, sr.Ended(), 1)
+}
+
+// TestConfig_WithDefaults verifies that defaults are applied to a copy, leaving the receiver untouched.
+func TestConfig_WithDefaults(t *testing.T) {
+	cfg := Config{Metric: MetricConfig{PrometheusPath: "/custom"}}
+	got := cfg.WithDefaults()
+
+	assert.Equal(t, "o11y", got.InstrumentationScope)
+	assert.Equal(t, ":2222", got.Metric.PrometheusAddr)
+	assert.Equal(t, "/custom", got.Metric.PrometheusPath)
+	assert.Empty(t, cfg.InstrumentationScope)
+	assert.Empty(t, cfg.Metric.PrometheusAddr)
+}
+
+// TestConfig_Clone verifies that a clone shares no slices, maps or pointers with the original.
+func TestConfig_Clone(t *testing.T) {
+	enabled := true
+	cfg := Config{
+		ResourceAttributes: map[string]string{"region": "eu"},
+		Log:                LogConfig{Enabled: &enabled, StackFilters: []string{"vendor/"}},
+		Trace:              TraceConfig{BaggageSpanAttributes: []string{"tenant_id"}},
+		Metric:             MetricConfig{DurationBuckets: []float64{0.1, 1}},
+	}
+	clone := cfg.Clone()
+	assert.Equal(t, cfg, clone)
+
+	clone.ResourceAttributes["region"] = "us"
+	*clone.Log.Enabled = false
+	clone.Log.StackFilters[0] = "internal/"
+	clone.Trace.BaggageSpanAttributes[0] = "user_id"
+	clone.Metric.DurationBuckets[0] = 0.5
+
+	assert.Equal(t, "eu", cfg.ResourceAttributes["region"])
+	assert.True(t, *cfg.Log.Enabled)
+	assert.Equal(t, "vendor/", cfg.Log.StackFilters[0])
+	assert.Equal(t, "tenant_id", cfg.Trace.BaggageSpanAttributes[0])
+	assert.Equal(t, 0.1, cfg.Metric.DurationBuckets[0])
+}
+
+// TestInitLoggingDisabled verifies that logging can be disabled independently of tracing and metrics.
+func TestInitLoggingDisabled(t *testing.T) {
+	var setupLoggingCalled bool
+	mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+		setupLoggingCalled = true
+		return zerolog.Nop(), func(ctx context.Context) error { return nil }
+	}
+	var setupTracingCalled bool
+	mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+		setupTracingCalled = true
+		return noopt.NewTracerProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+	mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+		return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+
+	disabled := false
+	cfg := Config{
+		Enabled: true,
+		Log:     LogConfig{Enabled: &disabled},
+		Trace:   TraceConfig{Enabled: true, Exporter: "none"},
+	}
+
+	p, err := New(cfg, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	assert.NoError(t, err)
+	defer p.Shutdown(context.Background())
+
+	assert.False(t, setupLoggingCalled, "logging should not be set up when disabled")
+	assert.True(t, setupTracingCalled, "tracing should still be set up")
+}
+
+// TestOnShutdown verifies that shutdown hooks run before the providers close and that their failures are collected.
+func TestOnShutdown(t *testing.T) {
+	var order []string
+	mockSetupLogging := func(cfg LogConfig) (zerolog.Logger, ShutdownFunc) {
+		return zerolog.Nop(), func(ctx context.Context) error { return nil }
+	}
+	mockSetupTracing := func(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
+		return noopt.NewTracerProvider(), func(ctx context.Context) error {
+			order = append(order, "tracing")
+			return nil
+		}, nil
+	}
+	mockSetupMetrics := func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error) {
+		return noop.NewMeterProvider(), func(ctx context.Context) error { return nil }, nil
+	}
+
+	shutdown, err := initialization(Config{Enabled: true}, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	assert.NoError(t, err)
+
+	OnShutdown(func(ctx context.Context) { order = append(order, "first") })
+	OnShutdown(func(ctx context.Context) { panic("boom") })
+	OnShutdown(func(ctx context.Context) { order = append(order, "last") })
+
+	err = shutdown(context.Background())
+	assert.ErrorContains(t, err, "shutdown hook 1 panicked: boom")
+	assert.Equal(t, []string{"first", "last", "tracing"}, order)
+
+	// Hooks exceeding the shutdown timeout don't block the providers from closing.
+	p, err := New(Config{Enabled: true}, mockSetupLogging, mockSetupTracing, mockSetupMetrics)
+	assert.NoError(t, err)
+	p.OnShutdown(func(ctx context.Context) { <-ctx.Done() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,49 @@
+// Package o11ytest provides helpers for testing services instrumented with o11y.
+// It is kept apart from package o11y so the testing package is not linked into production binaries.
+package o11ytest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestPropagation is a test helper verifying that the global propagator, as configured by o11y.Init,
+// understands the headers sent by an upstream service and reproduces them for downstream services.
+// It extracts the span context and baggage from inboundHeaders, injects them into a fresh carrier,
+// extracts them back, and fails the test if the context is missing or does not survive the round trip.
+// It returns the extracted trace and span IDs for further assertions.
+//
+// Example:
+//
+//	h := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+//	traceID, _ := o11ytest.TestPropagation(t, h)
+func TestPropagation(t testing.TB, inboundHeaders http.Header) (traceID, spanID string) {
+	t.Helper()
+	propagator := otel.GetTextMapPropagator()
+
+	ctx := propagator.Extract(context.Background(), propagation.HeaderCarrier(inboundHeaders))
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		t.Errorf("o11y: no valid span context extracted from headers %v with propagator fields %v", inboundHeaders, propagator.Fields())
+		return "", ""
+	}
+
+	outbound := http.Header{}
+	propagator.Inject(ctx, propagation.HeaderCarrier(outbound))
+	roundTrip := propagator.Extract(context.Background(), propagation.HeaderCarrier(outbound))
+
+	if got := trace.SpanContextFromContext(roundTrip); !got.Equal(sc.WithRemote(true)) {
+		t.Errorf("o11y: span context changed in propagation round trip: got %v, want %v", got, sc)
+	}
+	if got, want := baggage.FromContext(roundTrip).String(), baggage.FromContext(ctx).String(); got != want {
+		t.Errorf("o11y: baggage changed in propagation round trip: got %q, want %q", got, want)
+	}
+
+	return sc.TraceID().String(), sc.SpanID().String()
+}
@@ -0,0 +1,43 @@
+package o11ytest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/oy3o/o11y"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTB captures failures reported by a test helper instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTestPropagation(t *testing.T) {
+	shutdown, err := o11y.Init(o11y.Config{Enabled: true, Log: o11y.LogConfig{Level: "error"}})
+	assert.NoError(t, err)
+	defer shutdown(context.Background())
+
+	headers := http.Header{}
+	headers.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	headers.Set("baggage", "tenant_id=1001")
+
+	traceID, spanID := TestPropagation(t, headers)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "00f067aa0ba902b7", spanID)
+
+	// Headers the propagator does not understand are reported as a failure.
+	rec := &recordingTB{TB: t}
+	traceID, _ = TestPropagation(rec, http.Header{"X-B3-Traceid": {"4bf92f3577b34da6a3ce929d0e0e4736"}})
+	assert.Empty(t, traceID)
+	assert.Len(t, rec.errors, 1)
+}
@@ -2,8 +2,12 @@ package o11y
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel"
@@ -20,6 +24,10 @@ type Provider struct {
 	Logger zerolog.Logger
 
 	shutdownFunc ShutdownFunc
+
+	// hooks are the functions registered with OnShutdown.
+	hooksMu sync.Mutex
+	hooks   []func(ctx context.Context)
 }
 
 func New(cfg Config,
@@ -28,34 +36,31 @@ func New(cfg Config,
 	setupMetrics func(cfg MetricConfig, res *resource.Resource) (metric.MeterProvider, ShutdownFunc, error),
 ) (*Provider, error) {
 	// 1. Defaults
-	if cfg.InstrumentationScope == "" {
-		cfg.InstrumentationScope = "o11y"
-	}
-	if cfg.Metric.PrometheusAddr == "" {
-		cfg.Metric.PrometheusAddr = ":2222" // Default prometheus port
-	}
-	if cfg.Metric.PrometheusPath == "" {
-		cfg.Metric.PrometheusPath = "/metrics"
-	}
+	// Applied to a copy, so the caller can reuse cfg for another initialization.
+	cfg = cfg.WithDefaults()
+
+	tracerOpts := []trace.TracerOption{trace.WithInstrumentationVersion(cfg.InstrumentationVersion)}
+	meterOpts := []metric.MeterOption{metric.WithInstrumentationVersion(cfg.InstrumentationVersion)}
 
 	if !cfg.Enabled {
 		return &Provider{
-			Tracer:       otel.GetTracerProvider().Tracer(cfg.InstrumentationScope), // No-op
-			Meter:        otel.GetMeterProvider().Meter(cfg.InstrumentationScope),   // No-op
-			Logger:       zerolog.New(io.Discard),
+			Tracer:       otel.GetTracerProvider().Tracer(cfg.InstrumentationScope, tracerOpts...), // No-op
+			Meter:        otel.GetMeterProvider().Meter(cfg.InstrumentationScope, meterOpts...),    // No-op
+			Logger:       zerolog.Nop(),                                                            // Disabled, see GetLoggerFromContextOr
 			shutdownFunc: func(context.Context) error { return nil },
 		}, nil
 	}
 
 	// 2. Resource
+	// The service attributes come last so they take precedence over ResourceAttributes.
+	resAttrs := append(stringAttributes(cfg.ResourceAttributes),
+		semconv.ServiceName(cfg.Service),
+		semconv.ServiceVersion(cfg.Version),
+		semconv.DeploymentEnvironmentName(cfg.Environment),
+	)
 	res, err := resource.Merge(
 		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(cfg.Service),
-			semconv.ServiceVersion(cfg.Version),
-			semconv.DeploymentEnvironmentName(cfg.Environment),
-		),
+		resource.NewWithAttributes(semconv.SchemaURL, resAttrs...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OpenTelemetry resource: %w", err)
@@ -65,18 +70,23 @@ func New(cfg Config,
 	// We must ensure proper cleanup if any step fails.
 
 	// 3.1 Logging
-	logger, logShutdown := setupLogging(cfg.Log)
+	// A disabled logger still gets the global fields and hooks, it just writes nowhere.
+	// Its level is Disabled, so GetLoggerFromContextOr can tell it apart.
+	logger, logShutdown := zerolog.Nop(), ShutdownFunc(func(context.Context) error { return nil })
+	if cfg.Log.enabled() {
+		logger, logShutdown = setupLogging(cfg.Log)
+	}
 	log := logger.With().
 		Timestamp().
 		Str("service", cfg.Service).
 		Str("version", cfg.Version).
 		Str("environment", cfg.Environment).
 		Logger().
-		Hook(PanicHook(cfg.Log.StackFilters))
+		Hook(StackHook(cfg.Log.stackTraceLevel(), cfg.Log.stackFilters(), cfg.Log.stackFilterOptions()...))
 	log.Info().Msg("Logging initialized.")
 
 	// 3.2 Tracing
-	tp, traceShutdown, err := setupTracing(cfg.Trace, res)
+	tp, traceShutdown, err := setupTracing(cfg.traceConfig(), res)
 	if err != nil {
 		// Rollback Logging
 		logShutdown(context.Background())
@@ -85,7 +95,7 @@ func New(cfg Config,
 	log.Info().Msg("Tracing initialized.")
 
 	// 3.3 Metrics
-	mp, metricShutdown, err := setupMetrics(cfg.Metric, res)
+	mp, metricShutdown, err := setupMetrics(cfg.metricConfig(), res)
 	if err != nil {
 		// Rollback Tracing and Logging
 		traceShutdown(context.Background())
@@ -94,6 +104,9 @@ func New(cfg Config,
 	}
 	log.Info().Msg("Metrics initialized.")
 
+	// Summarize the effective configuration in a single line, to ease debugging misconfigured telemetry.
+	logConfigSummary(log, cfg)
+
 	// 4. Aggregate Shutdown
 	shutdown := func(ctx context.Context) error {
 		log.Info().Msg("Shutting down o11y components...")
@@ -141,14 +154,129 @@ func New(cfg Config,
 	}
 
 	return &Provider{
-		Tracer:       tp.Tracer(cfg.InstrumentationScope),
-		Meter:        mp.Meter(cfg.InstrumentationScope),
+		Tracer:       tp.Tracer(cfg.InstrumentationScope, tracerOpts...),
+		Meter:        mp.Meter(cfg.InstrumentationScope, meterOpts...),
 		Logger:       log,
 		shutdownFunc: shutdown,
 	}, nil
 }
 
+// logConfigSummary emits one info log describing what was actually initialized.
+// Only non-sensitive settings are included.
+func logConfigSummary(logger zerolog.Logger, cfg Config) {
+	e := logger.Info().
+		Str("log_level", zerolog.GlobalLevel().String()).
+		Bool("trace_enabled", cfg.Trace.Enabled).
+		Bool("metric_enabled", cfg.Metric.Enabled)
+
+	if cfg.Trace.Enabled {
+		// The ratio the sampler uses; setupTracing already warned if it was clamped.
+		ratio, _ := effectiveSampleRatio(cfg.Trace.SampleRatio)
+		e = e.Str("trace_exporter", cfg.Trace.Exporter).
+			Float64("trace_sample_ratio", ratio)
+		switch cfg.Trace.Exporter {
+		case "otlp-grpc":
+			e = e.Str("trace_endpoint", cfg.Trace.Endpoint)
+		case "file":
+			e = e.Str("trace_file_path", cfg.Trace.FilePath)
+		}
+	}
+	if cfg.Metric.Enabled {
+		exporters := cfg.Metric.exporters()
+		e = e.Str("metric_exporter", strings.Join(exporters, ","))
+		if slices.Contains(exporters, "prometheus") {
+			addr := MetricsServerAddr()
+			if addr == "" {
+				addr = cfg.Metric.PrometheusAddr
+			}
+			e = e.Str("metric_addr", addr+cfg.Metric.PrometheusPath)
+		}
+		if slices.Contains(exporters, "otlp-grpc") {
+			e = e.Str("metric_endpoint", cfg.metricConfig().Endpoint)
+		}
+	}
+
+	e.Msg("o11y initialized with effective configuration.")
+}
+
+// modulePath is the import path of this library, used to look up its version in the build info.
+const modulePath = "github.com/oy3o/o11y"
+
+// libraryVersion returns the version of the o11y module linked into the running binary.
+// It returns an empty string if the build info is unavailable.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
 // Shutdown 关闭 Provider
 func (p *Provider) Shutdown(ctx context.Context) error {
-	return p.shutdownFunc(ctx)
+	hookErr := p.runShutdownHooks(ctx)
+	return errors.Join(hookErr, p.shutdownFunc(ctx))
+}
+
+// OnShutdown registers fn to be called by Shutdown before the tracer, meter and logger
+// providers close, so it can still record last-gasp metrics (e.g. process uptime) or emit
+// a final span. Hooks run sequentially in registration order and receive the shutdown Context.
+// A panicking hook, or hooks still running when the Context expires, are reported in the
+// error returned by Shutdown, but never prevent the providers from closing.
+func (p *Provider) OnShutdown(fn func(ctx context.Context)) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.hooks = append(p.hooks, fn)
+}
+
+// runShutdownHooks runs the registered hooks, bounded by ctx.
+func (p *Provider) runShutdownHooks(ctx context.Context) error {
+	p.hooksMu.Lock()
+	hooks := p.hooks
+	p.hooks = nil
+	p.hooksMu.Unlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var errs error
+		for i, fn := range hooks {
+			if ctx.Err() != nil {
+				break
+			}
+			errs = errors.Join(errs, runShutdownHook(ctx, i, fn))
+		}
+		done <- errs
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown hooks did not complete: %w", ctx.Err())
+	}
+}
+
+// runShutdownHook calls fn, converting a panic into an error.
+func runShutdownHook(ctx context.Context, i int, fn func(ctx context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("shutdown hook %d panicked: %v", i, r)
+		}
+	}()
+	fn(ctx)
+	return nil
 }
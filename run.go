@@ -3,46 +3,109 @@ package o11y
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/puzpuzpuz/xsync/v4"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // Run is the flagship function of the o11y package.
 // It wraps a block of business logic, automatically providing it with comprehensive
 // observability: tracing, context-aware logging, and metrics for latency, calls, and errors.
+// Its behavior can be customized per call with RunOptions.
+// A Run nested in another one tags its span with the enclosing operation name as
+// "parent.operation", in addition to the attributes stored with WithContextAttributes.
 func Run(
 	ctx context.Context,
 	name string, // e.g., "ProcessOrder", "ValidateUserCredentials"
 	fn func(ctx context.Context, s State) error,
+	opts ...RunOption,
 ) (err error) {
+	o := newRunOptions(opts)
+
+	// Measure the time spent outside fn, including ending the span, as o11y.run.overhead.duration
+	// if MetricConfig.RecordRunOverhead is set.
+	recordOverhead := runOverhead.Load()
+	fnDuration := time.Duration(-1)
+	if recordOverhead {
+		runStart := time.Now()
+		defer func() {
+			// Not recorded if fn panicked, since its duration is unknown.
+			if fnDuration >= 0 {
+				overhead := time.Since(runStart) - fnDuration
+				RecordInInt64Histogram(ctx, "o11y.run.overhead.duration", overhead.Microseconds(), attribute.String("operation", name))
+			}
+		}()
+	}
+
 	// 1. Prepare Observability Objects
-	parentLogger := GetLoggerFromContext(ctx)
+	// Prefer the tracer of a Provider carried by the context; metrics always use the registry.
+	tracer := Tracer
+	if p, ok := ProviderFromContext(ctx); ok {
+		tracer = p.Tracer
+	}
 
-	ctxWithSpan, span := Tracer.Start(ctx, name)
-	defer span.End()
+	var codeAttrs []attribute.KeyValue
+	if o.codeAttributes {
+		codeAttrs = callerCodeAttributes()
+	}
 
-	// Create a new logger enriched with the span context.
-	spanLogger := parentLogger.With().
-		Str("trace_id", span.SpanContext().TraceID().String()).
-		Str("span_id", span.SpanContext().SpanID().String()).
-		Str("operation", name).
-		Logger()
+	// Attributes stored with WithContextAttributes are applied to every span Run starts.
+	ctxWithSpan, span := ctx, trace.SpanFromContext(ctx)
+	if o.existingSpan && span.IsRecording() {
+		// Continue the caller's span; its owner is responsible for ending it.
+		span.SetAttributes(ContextAttributes(ctx)...)
+		span.SetAttributes(attribute.String("operation", name))
+		span.SetAttributes(codeAttrs...)
+		for _, link := range o.links {
+			span.AddLink(link)
+		}
+	} else {
+		attrs := ContextAttributes(ctx)
+		// Nested operations name their caller, so traces describe the call hierarchy.
+		if parent, ok := FromContext(ctx); ok && parent.operation != "" {
+			// Clip so the slice stored in the context is never appended to in place.
+			attrs = append(slices.Clip(attrs), attribute.String("parent.operation", parent.operation))
+		}
+		attrs = append(slices.Clip(attrs), codeAttrs...)
+		ctxWithSpan, span = tracer.Start(ctx, name,
+			trace.WithAttributes(attrs...),
+			trace.WithLinks(o.links...),
+		)
+		spansStarted.Add(1)
+		defer span.End()
+	}
+
+	// Warn on the span when the operation gets close to its deadline.
+	if stop := watchDeadline(ctx, span, o.deadlineFraction); stop != nil {
+		defer stop()
+	}
 
-	// Inject the enriched logger back into the context so inner calls use it.
-	ctxWithLogger := spanLogger.WithContext(ctxWithSpan)
+	// Create a new logger enriched with the span context, and inject it back into the context
+	// so inner calls use it. The fields of an enclosing Run are replaced, not duplicated.
+	ctxWithLogger, spanLogger := contextWithSpanLogger(ctxWithSpan, uncorrelatedLogger(ctx), span.SpanContext(), name)
 
 	s := State{
-		ctx:   ctxWithLogger,
-		Log:   spanLogger,
-		span:  span,
-		meter: Meter,
+		ctx:         ctxWithLogger,
+		operation:   name,
+		Log:         spanLogger,
+		span:        span,
+		tracer:      tracer,
+		status:      &spanStatus{},
+		metricAttrs: o.metricAttrs,
 	}
 
+	// Make the State reachable from nested calls that only receive the context.
+	ctxWithState := context.WithValue(ctxWithLogger, stateKey{}, s)
+
 	// 2. Automatic Panic Handling
 	defer func() {
 		if r := recover(); r != nil {
@@ -71,19 +134,42 @@ func Run(
 	startTime := time.Now()
 	defer func() {
 		duration := time.Since(startTime).Seconds()
-		operationAttr := attribute.String("operation", name)
-		s.RecordHistogram("biz.operation.duration", duration, operationAttr)
+		attrs := []attribute.KeyValue{attribute.String("operation", name)}
+		if outcome := s.status.getOutcome(); outcome != "" {
+			attrs = append(attrs, attribute.String("outcome", outcome))
+		}
+		s.RecordHistogram("biz.operation.duration", duration, attrs...)
 	}()
 
 	// 4. Execute business logic
-	err = fn(ctxWithLogger, s)
+	var fnStart time.Time
+	if recordOverhead {
+		fnStart = time.Now()
+	}
+	err = fn(ctxWithState, s)
+	if recordOverhead {
+		fnDuration = time.Since(fnStart)
+	}
 
 	// 5. Result Handling
 	operationAttr := attribute.String("operation", name)
-	if err != nil {
+	failed := err != nil
+	if o.isIgnored(err) {
+		// Expected errors are returned to the caller but reported as success.
+		span.AddEvent("ignored_error", trace.WithAttributes(attribute.String("exception.message", err.Error())))
+		failed = false
+	}
+	if failed {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		s.IncCounter("biz.operation.error.total", operationAttr)
+	} else if code, description, ok := s.status.get(); ok {
+		// Respect a status set by the user via s.SetStatus.
+		// OTel only keeps the description for codes.Error, so record it as an attribute as well.
+		span.SetStatus(code, description)
+		if code != codes.Error && description != "" {
+			span.SetAttributes(attribute.String("operation.status_description", description))
+		}
 	} else {
 		span.SetStatus(codes.Ok, "success")
 		// No more MetricOptions handling here.
@@ -93,15 +179,176 @@ func Run(
 	return err
 }
 
+// watchDeadline schedules the deadline_approaching event of WithDeadlineWarning on span.
+// It returns a function canceling it, or nil if nothing was scheduled.
+func watchDeadline(ctx context.Context, span trace.Span, fraction float64) func() {
+	if fraction <= 0 || fraction >= 1 {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil
+	}
+
+	timer := time.AfterFunc(time.Duration(float64(remaining)*fraction), func() {
+		span.AddEvent("deadline_approaching", trace.WithAttributes(
+			attribute.Int64("deadline.remaining_ms", time.Until(deadline).Milliseconds()),
+		))
+	})
+	return func() { timer.Stop() }
+}
+
+// RunAuto is like Run, but derives the operation name from the calling function,
+// e.g. "orders.(*Service).Place" for a call inside the Place method of package orders.
+// Closures are attributed to their enclosing function. This keeps span names in sync with
+// the code during refactors; use Run when a custom label is preferable.
+//
+// The caller lookup costs a runtime.Caller call (a few hundred nanoseconds) per invocation;
+// resolving the program counter to a name is cached.
+func RunAuto(ctx context.Context, fn func(ctx context.Context, s State) error, opts ...RunOption) error {
+	return Run(ctx, callerName(2), fn, opts...)
+}
+
+// RunQueued is like Run, for operations taken from a queue: it measures the time the operation
+// waited since enqueuedAt, records it in the biz.operation.queue_wait histogram (in seconds)
+// and sets it on the span as "queue.wait_ms". The wait ends when RunQueued is called, so call
+// it as soon as the item is dequeued. A zero enqueuedAt records nothing; a wait made negative by
+// clock skew between the producer and the consumer is recorded as zero.
+//
+// Example:
+//
+//	err := o11y.RunQueued(ctx, "SendEmail", job.EnqueuedAt, send)
+func RunQueued(
+	ctx context.Context,
+	name string,
+	enqueuedAt time.Time,
+	fn func(ctx context.Context, s State) error,
+	opts ...RunOption,
+) error {
+	if enqueuedAt.IsZero() {
+		return Run(ctx, name, fn, opts...)
+	}
+	wait := max(time.Since(enqueuedAt), 0)
+	return Run(ctx, name, func(ctx context.Context, s State) error {
+		s.SetAttributes(attribute.Int64("queue.wait_ms", wait.Milliseconds()))
+		s.RecordHistogram("biz.operation.queue_wait", wait.Seconds(), attribute.String("operation", name))
+		return fn(ctx, s)
+	}, opts...)
+}
+
+// packagePrefix is the prefix of the qualified names of the functions of this package.
+const packagePrefix = "github.com/oy3o/o11y."
+
+// callerCodeAttributes returns the code.* attributes of the first caller outside this package.
+func callerCodeAttributes() []attribute.KeyValue {
+	var pcs [16]uintptr
+	// Skip runtime.Callers and callerCodeAttributes itself.
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return []attribute.KeyValue{
+				semconv.CodeFunctionName(frame.Function),
+				semconv.CodeFilePath(frame.File),
+				semconv.CodeLineNumber(frame.Line),
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// callerNames caches the operation names derived by callerName, keyed by program counter.
+var callerNames = xsync.NewMap[uintptr, string]()
+
+// callerName returns the short name of the function skip frames above it.
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	if name, ok := callerNames.Load(pc); ok {
+		return name
+	}
+
+	name := "unknown"
+	if f := runtime.FuncForPC(pc); f != nil {
+		name = shortFuncName(f.Name())
+	}
+	callerNames.Store(pc, name)
+	return name
+}
+
+// shortFuncName strips the import path and closure suffixes from a fully qualified function name,
+// e.g. "github.com/acme/orders.(*Service).Place.func1" -> "orders.(*Service).Place".
+func shortFuncName(name string) string {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	// Closures are named "Outer.func1", nested ones "Outer.func1.2".
+	for {
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return name
+		}
+		last := name[i+1:]
+		if !isDigits(last) && !(strings.HasPrefix(last, "func") && isDigits(last[len("func"):])) {
+			return name
+		}
+		name = name[:i]
+	}
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // GetLoggerFromContext is a helper function to safely retrieve a zerolog.Logger from a context.
-// If no logger is found in the context, it returns the global default logger.
+// If no logger is found in the context, it returns the logger of the Provider stored with
+// ContextWithProvider, or the global default logger if there is none. Those are returned as
+// copies, so modifying the result never changes the Provider or global logger.
+//
+// The result may be a disabled logger that writes nothing, e.g. after Init with Enabled or
+// Log.Enabled set to false. Use GetLoggerFromContextOr to get a fallback logger instead.
 func GetLoggerFromContext(ctx context.Context) *zerolog.Logger {
 	// zerolog.Ctx(ctx) handles the case where no logger is in the context
 	// by returning a disabled logger. We'll check its output writer and if it's
-	// a disabled logger, we return the global logger instead.
+	// a disabled logger, we return the fallback logger instead.
 	l := zerolog.Ctx(ctx)
 	if l.GetLevel() == zerolog.Disabled {
-		return &log.Logger
+		fallback := log.Logger
+		if p, ok := ProviderFromContext(ctx); ok {
+			fallback = p.Logger
+		}
+		return &fallback
 	}
 	return l
 }
+
+// GetLoggerFromContextOr is like GetLoggerFromContext, but returns fallback instead of a
+// disabled logger, e.g. so a library keeps its own logging when o11y logging is turned off.
+//
+// Example:
+//
+//	logger := o11y.GetLoggerFromContextOr(ctx, zerolog.New(os.Stderr))
+func GetLoggerFromContextOr(ctx context.Context, fallback zerolog.Logger) *zerolog.Logger {
+	if l := GetLoggerFromContext(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &fallback
+}
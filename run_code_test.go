@@ -0,0 +1,49 @@
+package o11y_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/oy3o/o11y"
+)
+
+// TestRun_CodeAttributes lives outside the package, so Run sees it as an ordinary caller.
+func TestRun_CodeAttributes(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	oldTracer := o11y.Tracer
+	o11y.Tracer = tp.Tracer("test")
+	t.Cleanup(func() { o11y.Tracer = oldTracer })
+
+	noop := func(ctx context.Context, s o11y.State) error { return nil }
+
+	_, _, line, _ := runtime.Caller(0)
+	_ = o11y.Run(context.Background(), "op", noop, o11y.WithCodeAttributes())
+	// Frames of the package are skipped, so the retry attempts point here too.
+	_ = o11y.RunWithRetry(context.Background(), "retried", o11y.RetryPolicy{}, noop, o11y.WithCodeAttributes())
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	for _, span := range spans {
+		attrs := attribute.NewSet(span.Attributes()...)
+		function, _ := attrs.Value("code.function.name")
+		assert.Equal(t, "github.com/oy3o/o11y_test.TestRun_CodeAttributes", function.AsString(), span.Name())
+		file, _ := attrs.Value("code.file.path")
+		assert.True(t, strings.HasSuffix(file.AsString(), "run_code_test.go"), file.AsString())
+	}
+	first := attribute.NewSet(spans[0].Attributes()...)
+	lineNo, _ := first.Value("code.line.number")
+	assert.Equal(t, int64(line+1), lineNo.AsInt64())
+
+	// Off by default.
+	_ = o11y.Run(context.Background(), "plain", noop)
+	plain := attribute.NewSet(sr.Ended()[3].Attributes()...)
+	assert.False(t, plain.HasValue("code.function.name"))
+}
@@ -0,0 +1,107 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// RunGroup fans out several independent operations concurrently, each wrapped in its own o11y.Run.
+// It starts a parent span named `name`; every entry in fns becomes a child operation named by its key.
+// Panics are recovered per goroutine by Run, and all errors are collected rather than cancelling
+// the siblings on the first failure. The returned error joins every failed operation's error,
+// each prefixed with the operation name, in name order.
+//
+// The parent span records the number of operations ("group.size") and how many of them failed
+// ("group.failed"), and links to the span of every child, tagged with its "operation" name, so
+// the children can be found from the parent in backends that do not show the hierarchy.
+// Each goroutine is counted in the o11y.goroutines.active gauge while it runs.
+// Concurrency is unbounded unless limited with WithGroupLimit. WithLinks and WithExistingSpan
+// shape the parent span only; the other RunOptions are applied to the parent and to every child.
+//
+// Example:
+//
+//	err := o11y.RunGroup(ctx, "LoadDashboard", map[string]func(context.Context, o11y.State) error{
+//	    "LoadProfile": loadProfile,
+//	    "LoadOrders":  loadOrders,
+//	}, o11y.WithGroupLimit(4))
+func RunGroup(
+	ctx context.Context,
+	name string,
+	fns map[string]func(ctx context.Context, s State) error,
+	opts ...RunOption,
+) error {
+	o := newRunOptions(opts)
+	childOpts := childRunOptions(opts)
+
+	return Run(ctx, name, func(ctx context.Context, s State) error {
+		// Sort the names so errors are reported in a deterministic order.
+		names := make([]string, 0, len(fns))
+		for n := range fns {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+
+		var (
+			g    errgroup.Group
+			mu   sync.Mutex
+			errs = make(map[string]error)
+			// children holds the span context of every child, linked from the parent once done.
+			children = make(map[string]trace.SpanContext, len(names))
+		)
+		helperAttr := attribute.String("helper", "RunGroup")
+		if o.groupLimit > 0 {
+			g.SetLimit(o.groupLimit)
+		}
+
+		for _, n := range names {
+			fn := fns[n]
+			g.Go(func() error {
+				// Track goroutines managed by the library, so leaks are alertable.
+				AddToInt64UpDownCounter(ctx, "o11y.goroutines.active", 1, helperAttr)
+				defer AddToInt64UpDownCounter(ctx, "o11y.goroutines.active", -1, helperAttr)
+
+				err := Run(ctx, n, func(ctx context.Context, cs State) error {
+					mu.Lock()
+					children[n] = trace.SpanContextFromContext(ctx)
+					mu.Unlock()
+					return fn(ctx, cs)
+				}, childOpts...)
+				if err != nil {
+					mu.Lock()
+					errs[n] = err
+					mu.Unlock()
+				}
+				// Never fail the errgroup, so siblings keep running.
+				return nil
+			})
+		}
+		_ = g.Wait()
+
+		span := trace.SpanFromContext(ctx)
+		for _, n := range names {
+			if sc, ok := children[n]; ok && sc.IsValid() {
+				span.AddLink(trace.Link{SpanContext: sc, Attributes: []attribute.KeyValue{attribute.String("operation", n)}})
+			}
+		}
+
+		s.SetAttributes(
+			attribute.Int("group.size", len(names)),
+			attribute.Int("group.failed", len(errs)),
+		)
+
+		var joined []error
+		for _, n := range names {
+			if err, ok := errs[n]; ok {
+				joined = append(joined, fmt.Errorf("%s: %w", n, err))
+			}
+		}
+		return errors.Join(joined...)
+	}, opts...)
+}
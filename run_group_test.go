@@ -0,0 +1,120 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRunGroup(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	var running, maxRunning atomic.Int32
+	track := func() {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+	}
+
+	errB := errors.New("b failed")
+	err := RunGroup(context.Background(), "group", map[string]func(context.Context, State) error{
+		"a": func(ctx context.Context, s State) error { track(); return nil },
+		"b": func(ctx context.Context, s State) error { track(); return errB },
+		"c": func(ctx context.Context, s State) error { track(); panic("c exploded") },
+		"d": func(ctx context.Context, s State) error { track(); return nil },
+	}, WithGroupLimit(2))
+
+	assert.ErrorIs(t, err, errB)
+	assert.ErrorContains(t, err, "b: b failed")
+	assert.ErrorContains(t, err, "c: panic recovered")
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 5)
+	parent := spans[len(spans)-1]
+	assert.Equal(t, "group", parent.Name())
+	assert.Contains(t, parent.Attributes(), attribute.Int("group.size", 4))
+	assert.Contains(t, parent.Attributes(), attribute.Int("group.failed", 2))
+	for _, child := range spans[:4] {
+		assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+	}
+
+	// The parent links to every child, in name order.
+	children := make(map[string]trace.SpanContext)
+	for _, child := range spans[:4] {
+		children[child.Name()] = child.SpanContext()
+	}
+	links := parent.Links()
+	assert.Len(t, links, 4)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, children[name], links[i].SpanContext)
+		assert.Contains(t, links[i].Attributes, attribute.String("operation", name))
+	}
+}
+
+func TestRunGroup_ParentOnlyOptions(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	ctx, server := Tracer.Start(context.Background(), "server")
+	link := trace.Link{SpanContext: server.SpanContext()}
+
+	_ = RunGroup(ctx, "group", map[string]func(context.Context, State) error{
+		"a": func(ctx context.Context, s State) error { return nil },
+		"b": func(ctx context.Context, s State) error { return errors.New("b failed") },
+	}, WithExistingSpan(), WithLinks(link))
+	server.End()
+
+	// The parent continues the server span; each child is a new span of its own without the links.
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	for _, child := range spans[:2] {
+		assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+		assert.Empty(t, child.Links())
+	}
+	assert.Equal(t, "server", spans[2].Name())
+	assert.Contains(t, spans[2].Attributes(), attribute.String("operation", "group"))
+	// The server span holds the group's link plus one per child.
+	assert.Len(t, spans[2].Links(), 3)
+}
+
+func TestRunGroup_ActiveGoroutines(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	var active, launched atomic.Int64
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "o11y.goroutines.active" {
+			active.Add(value)
+			if value > 0 {
+				launched.Add(value)
+			}
+		}
+	}
+	defer resetMetricFuncs()
+
+	noop := func(ctx context.Context, s State) error { return nil }
+	err := RunGroup(context.Background(), "group", map[string]func(context.Context, State) error{
+		"a": noop,
+		"b": noop,
+		"c": func(ctx context.Context, s State) error { panic("boom") },
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), launched.Load())
+	assert.Equal(t, int64(0), active.Load(), "all goroutines should be accounted as finished")
+}
@@ -0,0 +1,173 @@
+package o11y
+
+import (
+	"errors"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RunOption defines a function that customizes the behavior of a single o11y.Run call.
+type RunOption func(*runOptions)
+
+// runOptions holds the per-call settings collected from RunOptions.
+type runOptions struct {
+	// ignoreError reports whether an error returned from fn should be treated as success.
+	ignoreError func(error) bool
+
+	// groupLimit bounds the number of concurrently running operations in RunGroup.
+	// Zero or negative means unbounded.
+	groupLimit int
+
+	// links are attached to the span started by Run.
+	links []trace.Link
+
+	// existingSpan makes Run reuse the active recording span instead of starting a child.
+	existingSpan bool
+
+	// metricAttrs are added to every metric recorded through the State of the Run.
+	metricAttrs []attribute.KeyValue
+
+	// codeAttributes makes Run record its caller as code.* span attributes.
+	codeAttributes bool
+
+	// deadlineFraction, if in (0, 1), is the fraction of the time left before the context
+	// deadline after which Run adds a deadline_approaching event to its span.
+	deadlineFraction float64
+}
+
+// newRunOptions applies the given options on top of the defaults.
+func newRunOptions(opts []RunOption) runOptions {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// childRunOptions returns opts for the child operations of RunGroup and RunWithRetry.
+// The children are always new spans under the parent span, which alone carries the links.
+func childRunOptions(opts []RunOption) []RunOption {
+	return append(slices.Clip(opts), func(o *runOptions) {
+		o.links = nil
+		o.existingSpan = false
+	})
+}
+
+// isIgnored reports whether err is an expected error that must not mark the operation as failed.
+func (o runOptions) isIgnored(err error) bool {
+	return err != nil && o.ignoreError != nil && o.ignoreError(err)
+}
+
+// WithIgnoredErrors marks errors that are expected control-flow signals (e.g. ErrNotFound).
+// Matching is done with errors.Is, so wrapped errors are recognized as well.
+// A matching error is still returned to the caller, but the span status and the
+// biz.operation.error.total counter treat the operation as successful.
+// This prevents benign errors from polluting error-rate SLOs.
+//
+// Example:
+//
+//	err := o11y.Run(ctx, "FindUser", fn, o11y.WithIgnoredErrors(sql.ErrNoRows))
+func WithIgnoredErrors(errs ...error) RunOption {
+	return WithIgnoredErrorFunc(func(err error) bool {
+		for _, target := range errs {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithIgnoredErrorFunc is like WithIgnoredErrors but uses a predicate to decide
+// whether an error is expected. Multiple predicates are combined with OR.
+func WithIgnoredErrorFunc(ignore func(error) bool) RunOption {
+	return func(o *runOptions) {
+		prev := o.ignoreError
+		if prev == nil {
+			o.ignoreError = ignore
+			return
+		}
+		o.ignoreError = func(err error) bool {
+			return prev(err) || ignore(err)
+		}
+	}
+}
+
+// WithGroupLimit bounds the number of operations RunGroup runs concurrently.
+// A value of zero or less means no limit. It has no effect on a plain o11y.Run.
+func WithGroupLimit(n int) RunOption {
+	return func(o *runOptions) {
+		o.groupLimit = n
+	}
+}
+
+// WithLinks attaches links to the span started by Run, correlating it with other traces,
+// e.g. the operation that enqueued an async job (see LinkFromCarrier).
+// Links without a valid span context are ignored.
+//
+// Example:
+//
+//	err := o11y.Run(ctx, "ProcessJob", fn, o11y.WithLinks(o11y.LinkFromCarrier(job.TraceCarrier)))
+func WithLinks(links ...trace.Link) RunOption {
+	return func(o *runOptions) {
+		for _, link := range links {
+			if link.SpanContext.IsValid() {
+				o.links = append(o.links, link)
+			}
+		}
+	}
+}
+
+// WithExistingSpan makes Run continue the span already active in the context, such as the
+// server span started by otelhttp or otelgrpc, instead of starting a child span.
+// Metrics, logger enrichment and the span status are handled as usual, but Run does not end the span.
+// The span is tagged with the "operation" attribute set to the Run name.
+// If the context has no recording span, Run starts a new one as if the option was not given.
+//
+// This avoids an extra span layer in simple handlers where the server span already covers the operation.
+func WithExistingSpan() RunOption {
+	return func(o *runOptions) {
+		o.existingSpan = true
+	}
+}
+
+// WithMetricAttributes sets default attributes (e.g. region) added to every metric recorded
+// through the State of the Run: IncCounter, RecordHistogram and Record, including the
+// biz.operation.* metrics recorded by Run itself. An attribute passed explicitly to a call
+// overrides a default with the same key. Repeated options accumulate.
+//
+// Example:
+//
+//	err := o11y.Run(ctx, "PlaceOrder", fn, o11y.WithMetricAttributes(attribute.String("region", region)))
+func WithMetricAttributes(attrs ...attribute.KeyValue) RunOption {
+	return func(o *runOptions) {
+		o.metricAttrs = append(o.metricAttrs, attrs...)
+	}
+}
+
+// WithCodeAttributes records the caller of Run on its span as the semantic convention
+// attributes code.function.name, code.file.path and code.line.number, which tracing backends
+// can display next to the span. Frames of this package, such as RunWithRetry, are skipped.
+// The caller is looked up on every call (about a microsecond), hence the opt-in.
+func WithCodeAttributes() RunOption {
+	return func(o *runOptions) {
+		o.codeAttributes = true
+	}
+}
+
+// WithDeadlineWarning adds a "deadline_approaching" event to the span of Run once the given
+// fraction (e.g. 0.8) of the time left before the context deadline has elapsed, so operations
+// that nearly time out stand out in traces. The event carries the remaining time in
+// "deadline.remaining_ms". It has no effect if the context has no deadline, if the operation
+// finishes first, or if fraction is not between 0 and 1.
+//
+// Example:
+//
+//	err := o11y.Run(ctx, "Checkout", fn, o11y.WithDeadlineWarning(0.8))
+func WithDeadlineWarning(fraction float64) RunOption {
+	return func(o *runOptions) {
+		o.deadlineFraction = fraction
+	}
+}
@@ -0,0 +1,116 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RetryPolicy configures RunWithRetry. Zero fields take the documented defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Defaults to 3.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after every retry. Defaults to 2.
+	Multiplier float64
+
+	// Retryable reports whether a failed attempt is worth retrying, e.g. a timeout or an
+	// "unavailable" error from a downstream service. When nil, every error is retried.
+	Retryable func(error) bool
+}
+
+// withDefaults returns the policy with unset fields replaced by their defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	return p
+}
+
+// retryable reports whether err may be retried under the policy.
+func (p RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for range retry - 1 {
+		d *= p.Multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+// RunWithRetry is like Run, but retries fn on transient failures according to policy.
+// It starts a parent span named `name`; every attempt is a child operation named
+// "<name>.attempt" carrying an "attempt" attribute, starting at 1. An attempt is retried
+// when it fails with an error accepted by policy.Retryable and attempts remain; each retry
+// increments biz.operation.retry.total and waits for the policy backoff.
+//
+// The parent span records the number of attempts ("retry.attempts") and reports the
+// outcome of the last attempt. If ctx is canceled while waiting, the last error is
+// returned joined with the context error. WithLinks and WithExistingSpan shape the parent
+// span only; the other RunOptions are applied to the parent and to every attempt.
+//
+// Example:
+//
+//	err := o11y.RunWithRetry(ctx, "ChargeCard", o11y.RetryPolicy{
+//	    MaxAttempts: 5,
+//	    Retryable:   isUnavailable,
+//	}, charge)
+func RunWithRetry(
+	ctx context.Context,
+	name string,
+	policy RetryPolicy,
+	fn func(ctx context.Context, s State) error,
+	opts ...RunOption,
+) error {
+	policy = policy.withDefaults()
+
+	attemptOpts := childRunOptions(opts)
+
+	return Run(ctx, name, func(ctx context.Context, s State) error {
+		operationAttr := attribute.String("operation", name)
+
+		for attempt := 1; ; attempt++ {
+			err := Run(ctx, name+".attempt", func(ctx context.Context, as State) error {
+				as.SetAttributes(attribute.Int("attempt", attempt))
+				return fn(ctx, as)
+			}, attemptOpts...)
+
+			if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				s.SetAttributes(attribute.Int("retry.attempts", attempt))
+				return err
+			}
+
+			s.IncCounter("biz.operation.retry.total", operationAttr)
+			s.Log.Warn().Err(err).Int("attempt", attempt).Msg("Operation attempt failed, retrying")
+
+			timer := time.NewTimer(policy.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				s.SetAttributes(attribute.Int("retry.attempts", attempt))
+				return errors.Join(err, ctx.Err())
+			}
+		}
+	}, opts...)
+}
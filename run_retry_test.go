@@ -0,0 +1,135 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRunWithRetry(t *testing.T) {
+	cfg := Config{Enabled: true, Metric: MetricConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+	retries := GetMetricValue("biz.operation.retry.total")
+
+	errTransient := errors.New("unavailable")
+	calls := 0
+	err := RunWithRetry(context.Background(), "charge", RetryPolicy{InitialBackoff: time.Millisecond},
+		func(ctx context.Context, s State) error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, retries+2, GetMetricValue("biz.operation.retry.total"))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 4)
+	parent := spans[3]
+	assert.Equal(t, "charge", parent.Name())
+	assert.Equal(t, codes.Ok, parent.Status().Code)
+	assert.Contains(t, parent.Attributes(), attribute.Int("retry.attempts", 3))
+	for i, attempt := range spans[:3] {
+		assert.Equal(t, "charge.attempt", attempt.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), attempt.Parent().SpanID())
+		assert.Contains(t, attempt.Attributes(), attribute.Int("attempt", i+1))
+	}
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestRunWithRetry_NotRetryable(t *testing.T) {
+	sr := setupSpanRecorder(t)
+
+	errPermanent := errors.New("card declined")
+	calls := 0
+	err := RunWithRetry(context.Background(), "charge", RetryPolicy{
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(err error) bool { return !errors.Is(err, errPermanent) },
+	}, func(ctx context.Context, s State) error {
+		calls++
+		return errPermanent
+	})
+
+	assert.ErrorIs(t, err, errPermanent)
+	assert.Equal(t, 1, calls)
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestRunWithRetry_MaxAttempts(t *testing.T) {
+	setupSpanRecorder(t)
+
+	errTransient := errors.New("unavailable")
+	calls := 0
+	err := RunWithRetry(context.Background(), "charge", RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		func(ctx context.Context, s State) error {
+			calls++
+			return errTransient
+		})
+
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRunWithRetry_ContextCanceled(t *testing.T) {
+	setupSpanRecorder(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errTransient := errors.New("unavailable")
+	err := RunWithRetry(ctx, "charge", RetryPolicy{InitialBackoff: time.Hour},
+		func(ctx context.Context, s State) error {
+			cancel()
+			return errTransient
+		})
+
+	assert.ErrorIs(t, err, errTransient)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunWithRetry_ParentOnlyOptions(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	ctx, server := Tracer.Start(context.Background(), "server")
+	link := trace.Link{SpanContext: server.SpanContext()}
+
+	calls := 0
+	_ = RunWithRetry(ctx, "charge", RetryPolicy{InitialBackoff: time.Millisecond},
+		func(ctx context.Context, s State) error {
+			calls++
+			if calls < 2 {
+				return errors.New("unavailable")
+			}
+			return nil
+		}, WithExistingSpan(), WithLinks(link))
+	server.End()
+
+	// The parent continues the server span; each attempt is a new child of it without the links.
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	for _, attempt := range spans[:2] {
+		assert.Equal(t, "charge.attempt", attempt.Name())
+		assert.Equal(t, server.SpanContext().SpanID(), attempt.Parent().SpanID())
+		assert.Empty(t, attempt.Links())
+	}
+	assert.Equal(t, "server", spans[2].Name())
+	assert.Contains(t, spans[2].Attributes(), attribute.Int("retry.attempts", 2))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}.withDefaults()
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+}
@@ -1,13 +1,26 @@
 package o11y
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestRun_Success(t *testing.T) {
@@ -60,6 +73,138 @@ func TestRun_Panic(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRun_PanicLogTraceID(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	err := Run(ctx, "test_panic_log", func(ctx context.Context, s State) error {
+		panic("oops")
+	})
+
+	assert.Error(t, err)
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, buf.String(), "Panic recovered during operation")
+	assert.Contains(t, buf.String(), `"trace_id":"`+spans[0].SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, buf.String(), `"span_id":"`+spans[0].SpanContext().SpanID().String()+`"`)
+}
+
+func TestRun_CustomIDKeys(t *testing.T) {
+	traceKey, spanKey := LogConfig{}.idKeys()
+	assert.Equal(t, "trace_id", traceKey)
+	assert.Equal(t, "span_id", spanKey)
+
+	original := spanFields.Load()
+	t.Cleanup(func() { spanFields.Store(original) })
+	setSpanFields(LogConfig{TraceIDKey: "traceID", SpanIDKey: "spanID"})
+
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	_ = Run(ctx, "test_id_keys", func(ctx context.Context, s State) error {
+		s.Log.Info().Msg("hello")
+		return nil
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, buf.String(), `"traceID":"`+spans[0].SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, buf.String(), `"spanID":"`+spans[0].SpanContext().SpanID().String()+`"`)
+	assert.NotContains(t, buf.String(), `"trace_id"`)
+}
+
+func TestRun_Traceparent(t *testing.T) {
+	original := spanFields.Load()
+	t.Cleanup(func() { spanFields.Store(original) })
+	setSpanFields(LogConfig{EnableTraceparent: true})
+
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	_ = Run(ctx, "test_traceparent", func(ctx context.Context, s State) error {
+		s.Log.Info().Msg("hello")
+		return nil
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	sc := spans[0].SpanContext()
+	assert.Contains(t, buf.String(), `"traceparent":"00-`+sc.TraceID().String()+`-`+sc.SpanID().String()+`-01"`)
+	assert.Contains(t, buf.String(), `"trace_id":"`+sc.TraceID().String()+`"`)
+}
+
+func TestRun_ParentOperation(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	ctx := WithContextAttributes(context.Background(), attribute.String("workflow_id", "w1"))
+
+	_ = Run(ctx, "outer", func(ctx context.Context, s State) error {
+		return Run(ctx, "inner", func(ctx context.Context, s State) error { return nil })
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	inner, outer := spans[0], spans[1]
+	assert.Contains(t, inner.Attributes(), attribute.String("parent.operation", "outer"))
+	assert.Contains(t, inner.Attributes(), attribute.String("workflow_id", "w1"))
+	assert.NotContains(t, attributeKeys(outer.Attributes()), attribute.Key("parent.operation"))
+	assert.Contains(t, outer.Attributes(), attribute.String("workflow_id", "w1"))
+}
+
+func TestState_Span(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	_ = Run(ctx, "outer", func(ctx context.Context, s State) error {
+		ctx, end := s.Span(ctx, "step")
+		GetLoggerFromContext(ctx).Info().Msg("in step")
+		end()
+		return nil
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	step, outer := spans[0], spans[1]
+	assert.Equal(t, "step", step.Name())
+	assert.Equal(t, outer.SpanContext().SpanID(), step.Parent().SpanID())
+	// Decoding keeps the last of duplicate keys, so they are counted separately.
+	assert.Equal(t, 1, strings.Count(buf.String(), `"span_id":`))
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, step.SpanContext().SpanID().String(), entry["span_id"])
+	assert.Equal(t, step.SpanContext().TraceID().String(), entry["trace_id"])
+	assert.Equal(t, "outer", entry["operation"])
+}
+
+func TestRun_NestedLoggerIDs(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	_ = Run(ctx, "outer", func(ctx context.Context, s State) error {
+		return Run(ctx, "inner", func(ctx context.Context, s State) error {
+			s.Log.Info().Msg("in inner")
+			return nil
+		})
+	})
+
+	inner := sr.Ended()[0]
+	assert.Equal(t, 1, strings.Count(buf.String(), `"span_id":`))
+	assert.Equal(t, 1, strings.Count(buf.String(), `"operation":`))
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, inner.SpanContext().SpanID().String(), entry["span_id"])
+	assert.Equal(t, "inner", entry["operation"])
+
+	// A State not created by Run starts spans with the package tracer.
+	_, end := State{}.Span(context.Background(), "detached")
+	end()
+	assert.Equal(t, "detached", sr.Ended()[2].Name())
+}
+
 func TestState_Baggage(t *testing.T) {
 	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
 	shutdown, _ := Init(cfg)
@@ -81,3 +226,487 @@ func TestState_Baggage(t *testing.T) {
 		return nil
 	})
 }
+
+// setupSpanRecorder installs a Tracer backed by an in-memory SpanRecorder
+// and restores the previous package Tracer when the test finishes.
+func setupSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	oldTracer := Tracer
+	Tracer = tp.Tracer("test")
+	t.Cleanup(func() { Tracer = oldTracer })
+	return sr
+}
+
+func TestState_SetStatus(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	// User-set status replaces the default success status.
+	_ = Run(context.Background(), "test_status_hit", func(ctx context.Context, s State) error {
+		s.SetStatus(codes.Ok, "cache hit")
+		return nil
+	})
+
+	// A returned error takes precedence over the user-set status.
+	_ = Run(context.Background(), "test_status_error", func(ctx context.Context, s State) error {
+		s.SetStatus(codes.Ok, "cache hit")
+		return errors.New("boom")
+	})
+
+	// Without SetStatus, the default success status is used.
+	_ = Run(context.Background(), "test_status_default", func(ctx context.Context, s State) error {
+		return nil
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, codes.Ok, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("operation.status_description", "cache hit"))
+	assert.Equal(t, sdktrace.Status{Code: codes.Error, Description: "boom"}, spans[1].Status())
+	assert.Equal(t, codes.Ok, spans[2].Status().Code)
+}
+
+func TestRun_WithIgnoredErrors(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	errNotFound := errors.New("not found")
+	var counted []string
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		counted = append(counted, name)
+	}
+	defer resetMetricFuncs()
+
+	// A wrapped ignored error is returned but treated as success.
+	err := Run(context.Background(), "test_ignored", func(ctx context.Context, s State) error {
+		return fmt.Errorf("lookup: %w", errNotFound)
+	}, WithIgnoredErrors(errNotFound))
+	assert.ErrorIs(t, err, errNotFound)
+
+	// Errors not matching the option still fail the operation.
+	err = Run(context.Background(), "test_not_ignored", func(ctx context.Context, s State) error {
+		return errors.New("boom")
+	}, WithIgnoredErrors(errNotFound))
+	assert.Error(t, err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Ok, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, []string{"biz.operation.error.total"}, counted)
+}
+
+func TestRun_WithMetricAttributes(t *testing.T) {
+	counted := map[string][]attribute.KeyValue{}
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		counted[name] = attributes
+	}
+	var histogram []attribute.KeyValue
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		histogram = attributes
+	}
+	defer resetMetricFuncs()
+
+	err := Run(context.Background(), "test_metric_attrs", func(ctx context.Context, s State) error {
+		s.IncCounter("cache.client.operation.total", attribute.String("result", "hit"), attribute.String("tier", "gold"))
+		return errors.New("boom")
+	}, WithMetricAttributes(attribute.String("region", "eu"), attribute.String("tier", "free")))
+	assert.Error(t, err)
+
+	// Explicit attributes override defaults with the same key.
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("region", "eu"),
+		attribute.String("result", "hit"),
+		attribute.String("tier", "gold"),
+	}, counted["cache.client.operation.total"])
+	// Run's own metrics carry the defaults as well.
+	assert.Contains(t, counted["biz.operation.error.total"], attribute.String("region", "eu"))
+	assert.Contains(t, counted["biz.operation.error.total"], attribute.String("operation", "test_metric_attrs"))
+	assert.Contains(t, histogram, attribute.String("region", "eu"))
+}
+
+func TestRun_OverheadMetrics(t *testing.T) {
+	setupSpanRecorder(t)
+	t.Cleanup(func() { runOverhead.Store(false) })
+	overhead := map[string]int64{}
+	recordInInt64HistogramFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "o11y.run.overhead.duration" {
+			overhead[attributes[0].Value.AsString()] = value
+		}
+	}
+	defer resetMetricFuncs()
+
+	// Off by default.
+	_ = Run(context.Background(), "off", func(ctx context.Context, s State) error { return nil })
+	assert.Empty(t, overhead)
+	runOverhead.Store(true)
+	spans := spansStarted.Load()
+
+	_ = Run(context.Background(), "slow", func(ctx context.Context, s State) error {
+		_, end := s.Span(ctx, "step")
+		end()
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	// The time spent in fn is excluded.
+	assert.Len(t, overhead, 1)
+	assert.Less(t, overhead["slow"], int64(20_000))
+	assert.Equal(t, spans+2, spansStarted.Load())
+
+	// Panics are not measured.
+	_ = Run(context.Background(), "panics", func(ctx context.Context, s State) error { panic("boom") })
+	assert.NotContains(t, overhead, "panics")
+}
+
+func TestRun_WithDeadlineWarning(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_ = Run(ctx, "slow", func(ctx context.Context, s State) error {
+		time.Sleep(60 * time.Millisecond)
+		return nil
+	}, WithDeadlineWarning(0.2))
+	_ = Run(ctx, "fast", func(ctx context.Context, s State) error { return nil }, WithDeadlineWarning(0.5))
+	// Without a deadline the option has no effect.
+	_ = Run(context.Background(), "no_deadline", func(ctx context.Context, s State) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, WithDeadlineWarning(0.01))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	events := spans[0].Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "deadline_approaching", events[0].Name)
+		assert.Equal(t, "deadline.remaining_ms", string(events[0].Attributes[0].Key))
+		assert.Positive(t, events[0].Attributes[0].Value.AsInt64())
+	}
+	assert.Empty(t, spans[1].Events())
+	assert.Empty(t, spans[2].Events())
+}
+
+func TestRun_WithContextAttributes(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	ctx := WithContextAttributes(context.Background(), attribute.String("workflow_id", "wf-1"))
+	_ = Run(ctx, "outer", func(ctx context.Context, s State) error {
+		ctx = WithContextAttributes(ctx, attribute.String("step", "charge"))
+		return Run(ctx, "inner", func(ctx context.Context, s State) error {
+			return nil
+		})
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	inner, outer := spans[0], spans[1]
+	assert.Contains(t, outer.Attributes(), attribute.String("workflow_id", "wf-1"))
+	assert.NotContains(t, outer.Attributes(), attribute.String("step", "charge"))
+	assert.Contains(t, inner.Attributes(), attribute.String("workflow_id", "wf-1"))
+	assert.Contains(t, inner.Attributes(), attribute.String("step", "charge"))
+}
+
+func TestRun_ContextProvider(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	var logBuffer bytes.Buffer
+	p := &Provider{
+		Tracer: tp.Tracer("provider"),
+		Meter:  Meter,
+		Logger: zerolog.New(&logBuffer),
+	}
+
+	ctx := ContextWithProvider(context.Background(), p)
+	got, ok := ProviderFromContext(ctx)
+	assert.True(t, ok)
+	assert.Same(t, p, got)
+
+	_ = Run(ctx, "test_provider", func(ctx context.Context, s State) error {
+		s.Log.Info().Msg("from provider logger")
+		return nil
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1, "span should be created by the context provider's tracer")
+	assert.Contains(t, logBuffer.String(), "from provider logger")
+
+	_, ok = ProviderFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestGetLoggerFromContextOr(t *testing.T) {
+	originalLogger := log.Logger
+	t.Cleanup(func() { log.Logger = originalLogger })
+	var fallbackBuffer, globalBuffer bytes.Buffer
+	fallback := zerolog.New(&fallbackBuffer)
+
+	// With o11y disabled, the global logger is disabled and the fallback is used.
+	shutdown, _ := Init(Config{Enabled: false})
+	defer shutdown(context.Background())
+	assert.Equal(t, zerolog.Disabled, GetLoggerFromContext(context.Background()).GetLevel())
+	GetLoggerFromContextOr(context.Background(), fallback).Info().Msg("fallback")
+	assert.Contains(t, fallbackBuffer.String(), "fallback")
+
+	// An enabled logger is returned as is.
+	log.Logger = zerolog.New(&globalBuffer)
+	GetLoggerFromContextOr(context.Background(), fallback).Info().Msg("global")
+	assert.Contains(t, globalBuffer.String(), "global")
+	assert.NotContains(t, fallbackBuffer.String(), "global")
+
+	// The returned global logger is a copy.
+	l := GetLoggerFromContext(context.Background())
+	*l = l.Level(zerolog.ErrorLevel)
+	assert.Equal(t, zerolog.TraceLevel, log.Logger.GetLevel())
+}
+
+func TestState_LogEvent(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	var logBuffer bytes.Buffer
+	ctx := zerolog.New(&logBuffer).WithContext(context.Background())
+	_ = Run(ctx, "test_log_event", func(ctx context.Context, s State) error {
+		s.LogEvent(zerolog.WarnLevel, "cache miss", attribute.String("cache.key", "user:1"))
+		return nil
+	})
+
+	assert.Contains(t, logBuffer.String(), `"message":"cache miss"`)
+	assert.Contains(t, logBuffer.String(), `"cache.key":"user:1"`)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	events := spans[0].Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "cache miss", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.String("cache.key", "user:1"))
+	assert.Contains(t, events[0].Attributes, attribute.String("log.severity", "warn"))
+}
+
+func TestState_LogError(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+
+	var logBuffer bytes.Buffer
+	ctx := zerolog.New(&logBuffer).WithContext(context.Background())
+	_ = Run(ctx, "test_log_error", func(ctx context.Context, s State) error {
+		s.LogError(fmt.Errorf("charge: %w", errors.New("card declined")))
+		s.LogError(nil)
+		return nil
+	})
+
+	assert.Equal(t, 1, strings.Count(logBuffer.String(), `"error_chain"`))
+	assert.Contains(t, logBuffer.String(), `"error_chain":["charge: card declined","card declined"]`)
+}
+
+func TestFromContext(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	// nested only receives the context, yet records on the innermost operation.
+	nested := func(ctx context.Context) {
+		s, ok := FromContext(ctx)
+		assert.True(t, ok)
+		s.AddEvent("nested_event")
+	}
+
+	_ = Run(context.Background(), "outer", func(ctx context.Context, s State) error {
+		return Run(ctx, "inner", func(ctx context.Context, s State) error {
+			nested(ctx)
+			return nil
+		})
+	})
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "inner", spans[0].Name())
+	assert.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "nested_event", spans[0].Events()[0].Name)
+	assert.Empty(t, spans[1].Events())
+}
+
+func TestRun_WithLinks(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	// Capture the context of an originating operation in a carrier, like an async job would.
+	carrier := map[string]string{}
+	_ = Run(context.Background(), "enqueue", func(ctx context.Context, s State) error {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier))
+		return nil
+	})
+
+	link := LinkFromCarrier(carrier)
+	assert.True(t, link.SpanContext.IsValid())
+	assert.False(t, LinkFromCarrier(map[string]string{}).SpanContext.IsValid())
+
+	_ = Run(context.Background(), "process", func(ctx context.Context, s State) error {
+		return nil
+	}, WithLinks(link, LinkFromCarrier(nil)))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Len(t, spans[1].Links(), 1, "invalid links should be ignored")
+	assert.Equal(t, spans[0].SpanContext().TraceID(), spans[1].Links()[0].SpanContext.TraceID())
+	assert.NotEqual(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+}
+
+func TestRun_WithExistingSpan(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	// Simulate the server span started by otelhttp.
+	ctx, server := Tracer.Start(context.Background(), "GET /orders")
+	err := Run(ctx, "ListOrders", func(ctx context.Context, s State) error {
+		return errors.New("db down")
+	}, WithExistingSpan())
+	assert.Error(t, err)
+	assert.Empty(t, sr.Ended(), "Run must not end a span it did not start")
+	server.End()
+
+	// Without an active span, a new one is started.
+	_ = Run(context.Background(), "Standalone", func(ctx context.Context, s State) error {
+		return nil
+	}, WithExistingSpan())
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "GET /orders", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("operation", "ListOrders"))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "Standalone", spans[1].Name())
+}
+
+func TestRun_WithExistingSpan_Panic(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	// The adopted span gets the panic, and the logger its IDs, but stays open.
+	ctx, server := Tracer.Start(ctx, "GET /orders")
+	err := Run(ctx, "ListOrders", func(ctx context.Context, s State) error {
+		panic("boom")
+	}, WithExistingSpan())
+	assert.ErrorContains(t, err, "panic recovered")
+	assert.Empty(t, sr.Ended(), "Run must not end a span it did not start")
+	server.End()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, buf.String(), `"span_id":"`+server.SpanContext().SpanID().String()+`"`)
+}
+
+// runAutoCaller calls RunAuto from a named function, and from a closure within it.
+func runAutoCaller(ctx context.Context) {
+	_ = RunAuto(ctx, func(ctx context.Context, s State) error { return nil })
+	func() {
+		_ = RunAuto(ctx, func(ctx context.Context, s State) error { return nil })
+	}()
+}
+
+func TestRunAuto(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	runAutoCaller(context.Background())
+	runAutoCaller(context.Background()) // Served from the cache.
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 4)
+	for _, span := range spans {
+		assert.Equal(t, "o11y.runAutoCaller", span.Name())
+	}
+
+	assert.Equal(t, "orders.(*Service).Place", shortFuncName("github.com/acme/orders.(*Service).Place.func1.2"))
+	assert.Equal(t, "main.functional", shortFuncName("main.functional"))
+}
+
+func TestRunQueued(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	waits := map[string]float64{}
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		if name == "biz.operation.queue_wait" {
+			waits[attributes[0].Value.AsString()] = value
+		}
+	}
+	defer resetMetricFuncs()
+
+	err := RunQueued(context.Background(), "send", time.Now().Add(-50*time.Millisecond),
+		func(ctx context.Context, s State) error { return nil })
+	assert.NoError(t, err)
+	// Clock skew may put the enqueue time in the future.
+	_ = RunQueued(context.Background(), "skewed", time.Now().Add(time.Second),
+		func(ctx context.Context, s State) error { return nil })
+	_ = RunQueued(context.Background(), "unknown", time.Time{},
+		func(ctx context.Context, s State) error { return nil })
+
+	assert.GreaterOrEqual(t, waits["send"], 0.05)
+	assert.Less(t, waits["send"], 1.0)
+	assert.Zero(t, waits["skewed"])
+	assert.NotContains(t, waits, "unknown")
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3)
+	var waitMs int64
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "queue.wait_ms" {
+			waitMs = kv.Value.AsInt64()
+		}
+	}
+	assert.GreaterOrEqual(t, waitMs, int64(50))
+	assert.NotContains(t, attributeKeys(spans[2].Attributes()), attribute.Key("queue.wait_ms"))
+}
+
+func TestState_SetOutcome(t *testing.T) {
+	sr := setupSpanRecorder(t)
+	var durationAttrs []attribute.KeyValue
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		if name == "biz.operation.duration" {
+			durationAttrs = attributes
+		}
+	}
+	defer resetMetricFuncs()
+
+	_ = Run(context.Background(), "lookup", func(ctx context.Context, s State) error {
+		s.SetOutcome("miss")
+		s.SetOutcome("hit")
+		return nil
+	})
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("operation.outcome", "hit"))
+	assert.Equal(t, []attribute.KeyValue{attribute.String("operation", "lookup"), attribute.String("outcome", "hit")}, durationAttrs)
+
+	// Without an outcome the metric attributes are unchanged.
+	_ = Run(context.Background(), "lookup", func(ctx context.Context, s State) error { return nil })
+	assert.Equal(t, []attribute.KeyValue{attribute.String("operation", "lookup")}, durationAttrs)
+}
@@ -0,0 +1,152 @@
+package o11y
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// NewSlogHandler returns a slog.Handler writing records through the o11y logger, so that
+// libraries logging with log/slog share the configured outputs, levels and trace correlation.
+//
+// Each record is written with the logger of its context, as returned by GetLoggerFromContext,
+// so records logged inside Run carry the operation field of the enclosing operation. The trace
+// and span IDs of the span in the record's context, if any, are always included.
+// Attribute groups are flattened into dotted keys, e.g. "request.method".
+//
+// Example:
+//
+//	slog.SetDefault(slog.New(o11y.NewSlogHandler()))
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+// slogHandler implements slog.Handler on top of zerolog.
+type slogHandler struct {
+	// attrs are the attributes added with WithAttrs, each with the group prefix in effect then.
+	attrs []prefixedAttr
+	// prefix is the dotted path of the groups opened with WithGroup, e.g. "request.".
+	prefix string
+}
+
+// prefixedAttr is an attribute nested under the groups opened before it was added.
+type prefixedAttr struct {
+	prefix string
+	attr   slog.Attr
+}
+
+// Enabled reports whether the context logger would write records of the given level.
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	zl := zerologLevel(level)
+	return zl >= GetLoggerFromContext(nonNilContext(ctx)).GetLevel() && zl >= zerolog.GlobalLevel()
+}
+
+// Handle writes the record with the context logger.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	logger := correlatedLogger(nonNilContext(ctx))
+	e := logger.WithLevel(zerologLevel(r.Level))
+	if e == nil {
+		return nil
+	}
+
+	for _, pa := range h.attrs {
+		e = appendSlogAttr(e, pa.prefix, pa.attr)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		e = appendSlogAttr(e, h.prefix, a)
+		return true
+	})
+	e.Msg(r.Message)
+	return nil
+}
+
+// WithAttrs returns a handler adding attrs to every record.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = make([]prefixedAttr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(h2.attrs, h.attrs)
+	// The prefix is applied when the record is written, so keyless groups are inlined under it.
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, prefixedAttr{prefix: h.prefix, attr: a})
+	}
+	return &h2
+}
+
+// WithGroup returns a handler nesting subsequent attributes under name.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// nonNilContext returns ctx, or the background Context if ctx is nil, which slog permits.
+func nonNilContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// zerologLevel maps a slog level to the closest zerolog level.
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// appendSlogAttr adds a to e under prefix, flattening groups.
+func appendSlogAttr(e *zerolog.Event, prefix string, a slog.Attr) *zerolog.Event {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		// Groups without a key are inlined, as required by the slog.Handler contract.
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			e = appendSlogAttr(e, prefix, ga)
+		}
+		return e
+	}
+	if a.Key == "" {
+		return e
+	}
+
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindString:
+		return e.Str(key, v.String())
+	case slog.KindInt64:
+		return e.Int64(key, v.Int64())
+	case slog.KindUint64:
+		return e.Uint64(key, v.Uint64())
+	case slog.KindFloat64:
+		return e.Float64(key, v.Float64())
+	case slog.KindBool:
+		return e.Bool(key, v.Bool())
+	case slog.KindDuration:
+		return e.Dur(key, v.Duration())
+	case slog.KindTime:
+		return e.Time(key, v.Time())
+	default:
+		if err, ok := v.Any().(error); ok {
+			return e.AnErr(key, err)
+		}
+		return e.Interface(key, v.Any())
+	}
+}
@@ -0,0 +1,94 @@
+package o11y
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).Level(zerolog.InfoLevel).WithContext(context.Background())
+
+	logger := slog.New(NewSlogHandler()).With("component", "cache").WithGroup("request")
+	logger.InfoContext(ctx, "served",
+		"method", "GET",
+		"status", 200,
+		slog.Group("client", "ip", "10.0.0.1"),
+		"err", errors.New("boom"),
+	)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "served", entry["message"])
+	assert.Equal(t, "cache", entry["component"])
+	assert.Equal(t, "GET", entry["request.method"])
+	assert.Equal(t, float64(200), entry["request.status"])
+	assert.Equal(t, "10.0.0.1", entry["request.client.ip"])
+	assert.Equal(t, "boom", entry["request.err"])
+
+	// Levels below the logger level are filtered.
+	buf.Reset()
+	logger.DebugContext(ctx, "hidden")
+	assert.Empty(t, buf.String())
+	assert.False(t, logger.Enabled(ctx, slog.LevelDebug))
+	assert.True(t, logger.Enabled(ctx, slog.LevelWarn))
+}
+
+func TestSlogHandler_WithAttrsInGroup(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	logger := slog.New(NewSlogHandler()).WithGroup("req").With(
+		slog.Group("", "key", "v"),
+		slog.Group("user", "id", 7),
+		slog.String("", "dropped"),
+	)
+	logger.InfoContext(ctx, "served")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "v", entry["req.key"])
+	assert.Equal(t, float64(7), entry["req.user.id"])
+	assert.NotContains(t, entry, "req..key")
+	assert.NotContains(t, entry, "req.")
+}
+
+func TestSlogHandler_TraceCorrelation(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = original })
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	slog.New(NewSlogHandler()).WarnContext(ctx, "slow")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, sc.TraceID().String(), entry["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), entry["span_id"])
+}
+
+func TestZerologLevel(t *testing.T) {
+	assert.Equal(t, zerolog.TraceLevel, zerologLevel(slog.LevelDebug-1))
+	assert.Equal(t, zerolog.DebugLevel, zerologLevel(slog.LevelDebug))
+	assert.Equal(t, zerolog.InfoLevel, zerologLevel(slog.LevelInfo))
+	assert.Equal(t, zerolog.WarnLevel, zerologLevel(slog.LevelWarn+1))
+	assert.Equal(t, zerolog.ErrorLevel, zerologLevel(slog.LevelError+4))
+}
@@ -0,0 +1,83 @@
+package o11y
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQLDB is a thin wrapper around *sql.DB bound to the current o11y.Run operation.
+// Each query runs in a child span named after an explicit, business-level label
+// (e.g. "LoadUserProfile"), and its latency is recorded in db.client.query.duration
+// with the label as the db.operation.name attribute, plus the attributes of WithMetricAttributes.
+//
+// When the *sql.DB comes from OpenSQL or OpenDBWithConnector, the driver-level spans
+// (with the SQL text) nest under the labeled span, giving a readable trace hierarchy.
+type SQLDB struct {
+	db *sql.DB
+	s  State
+}
+
+// SQL binds db to the current operation. See SQLDB.
+//
+// Example:
+//
+//	err := o11y.Run(ctx, "GetUser", func(ctx context.Context, s o11y.State) error {
+//	    row := s.SQL(db).QueryRowContext(ctx, "LoadUserProfile", "SELECT name FROM users WHERE id = $1", id)
+//	    return row.Scan(&name)
+//	})
+func (s State) SQL(db *sql.DB) SQLDB {
+	return SQLDB{db: db, s: s}
+}
+
+// QueryContext executes a query that returns rows under a span named label.
+func (d SQLDB) QueryContext(ctx context.Context, label, query string, args ...any) (*sql.Rows, error) {
+	ctx, finish := d.start(ctx, label)
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	finish(err)
+	return rows, err
+}
+
+// QueryRowContext executes a query that is expected to return at most one row under a span named label.
+// Errors are deferred until the row is scanned, as with sql.DB.QueryRowContext, but are still
+// recorded on the span when available immediately.
+func (d SQLDB) QueryRowContext(ctx context.Context, label, query string, args ...any) *sql.Row {
+	ctx, finish := d.start(ctx, label)
+	row := d.db.QueryRowContext(ctx, query, args...)
+	finish(row.Err())
+	return row
+}
+
+// ExecContext executes a query without returning any rows under a span named label.
+func (d SQLDB) ExecContext(ctx context.Context, label, query string, args ...any) (sql.Result, error) {
+	ctx, finish := d.start(ctx, label)
+	res, err := d.db.ExecContext(ctx, query, args...)
+	finish(err)
+	return res, err
+}
+
+// start opens the labeled child span and returns a function that ends it and records the duration.
+func (d SQLDB) start(ctx context.Context, label string) (context.Context, func(err error)) {
+	opAttr := semconv.DBOperationName(label)
+	ctx, span := d.s.childTracer().Start(ctx, label,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(opAttr),
+	)
+	startTime := time.Now()
+
+	return ctx, func(err error) {
+		defer span.End()
+		// sql.ErrNoRows is an expected outcome, not a failure.
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		RecordInFloat64Histogram(ctx, "db.client.query.duration", time.Since(startTime).Seconds(), d.s.withMetricAttributes([]attribute.KeyValue{opAttr})...)
+	}
+}
@@ -0,0 +1,108 @@
+package o11y
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// fakeDriver is a minimal database/sql driver: queries return a single "ok" row,
+// and any statement equal to "FAIL" returns an error.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "FAIL" {
+		return nil, errors.New("query failed")
+	}
+	return &fakeRows{}, nil
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "FAIL" {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = "ok"
+	return nil
+}
+
+func init() {
+	sql.Register("o11y-fake", fakeDriver{})
+}
+
+func TestState_SQL(t *testing.T) {
+	cfg := Config{Enabled: true, Trace: TraceConfig{Enabled: true, Exporter: "none"}}
+	shutdown, _ := Init(cfg)
+	defer shutdown(context.Background())
+	sr := setupSpanRecorder(t)
+
+	var durations []attribute.KeyValue
+	recordInFloat64HistogramFunc = func(ctx context.Context, name string, value float64, attributes ...attribute.KeyValue) {
+		if name == "db.client.query.duration" {
+			durations = append(durations, attributes...)
+		}
+	}
+	defer resetMetricFuncs()
+
+	db, err := sql.Open("o11y-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_ = Run(context.Background(), "GetUser", func(ctx context.Context, s State) error {
+		var value string
+		require.NoError(t, s.SQL(db).QueryRowContext(ctx, "LoadUserProfile", "SELECT 1").Scan(&value))
+		assert.Equal(t, "ok", value)
+
+		_, err := s.SQL(db).ExecContext(ctx, "UpdateUser", "FAIL")
+		assert.Error(t, err)
+		return nil
+	}, WithMetricAttributes(attribute.String("region", "eu")))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3)
+	load, update, parent := spans[0], spans[1], spans[2]
+	assert.Equal(t, "LoadUserProfile", load.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), load.Parent().SpanID())
+	assert.NotEqual(t, codes.Error, load.Status().Code)
+	assert.Equal(t, "UpdateUser", update.Name())
+	assert.Equal(t, codes.Error, update.Status().Code)
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("region", "eu"),
+		attribute.String("db.operation.name", "LoadUserProfile"),
+		attribute.String("region", "eu"),
+		attribute.String("db.operation.name", "UpdateUser"),
+	}, durations)
+
+	// A State not created by Run falls back to the package-level Tracer.
+	_, err = State{}.SQL(db).ExecContext(context.Background(), "Orphan", "UPDATE 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Orphan", sr.Ended()[3].Name())
+}
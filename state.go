@@ -2,11 +2,13 @@ package o11y
 
 import (
 	"context"
+	"slices"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -17,6 +19,9 @@ type State struct {
 	//
 	ctx context.Context
 
+	// operation is the name of the o11y.Run operation, recorded as parent.operation on nested ones.
+	operation string
+
 	// Log is a zerolog.Logger instance pre-configured with the correct trace_id and span_id.
 	// Developers should use this for all logging within the o11y.Run block to ensure
 	// logs are automatically correlated with traces.
@@ -26,9 +31,54 @@ type State struct {
 	// It is kept private to encourage interaction via the simplified helper methods.
 	span trace.Span
 
-	// meter is the OpenTelemetry meter used to record metrics.
-	// It is also kept private.
-	meter metric.Meter
+	// tracer is the tracer that created span, used to start child spans.
+	tracer trace.Tracer
+
+	// status holds a span status set by the user via SetStatus.
+	// It is shared by all copies of the State so Run can apply it when fn returns.
+	status *spanStatus
+
+	// metricAttrs are the default metric attributes set with WithMetricAttributes.
+	metricAttrs []attribute.KeyValue
+}
+
+// withMetricAttributes merges the default metric attributes into attrs; attrs win on key collision.
+func (s State) withMetricAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(s.metricAttrs) == 0 {
+		return attrs
+	}
+	merged := make([]attribute.KeyValue, 0, len(s.metricAttrs)+len(attrs))
+	for _, def := range s.metricAttrs {
+		if !slices.ContainsFunc(attrs, func(kv attribute.KeyValue) bool { return kv.Key == def.Key }) {
+			merged = append(merged, def)
+		}
+	}
+	return append(merged, attrs...)
+}
+
+// spanStatus is a user-requested span status, applied by Run after fn returns.
+type spanStatus struct {
+	mu          sync.Mutex
+	set         bool
+	code        codes.Code
+	description string
+
+	// outcome is the value set with SetOutcome, recorded by Run on biz.operation.duration.
+	outcome string
+}
+
+// get returns the user-set status, if any.
+func (st *spanStatus) get() (codes.Code, string, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.code, st.description, st.set
+}
+
+// getOutcome returns the outcome set with SetOutcome, or "" if none was.
+func (st *spanStatus) getOutcome() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.outcome
 }
 
 // SetAttributes adds key-value attributes to the current trace span.
@@ -71,11 +121,119 @@ func (s State) SetBaggage(ctx context.Context, key, value string) context.Contex
 	return baggage.ContextWithBaggage(ctx, b)
 }
 
+// SetStatus overrides the final status of the current span.
+// By default, Run marks the span as (codes.Ok, "success") when fn returns nil.
+// Use SetStatus to replace that with a more descriptive status, e.g. "cache hit" vs "cache miss".
+//
+// Precedence: an error returned from fn (or a recovered panic) always wins and marks the span
+// as codes.Error with the error message. A user-set status only replaces the default success status.
+// Setting codes.Error here without returning an error marks the span as failed but does not
+// increment the operation error counter. The last call to SetStatus wins.
+//
+// OpenTelemetry discards the description of non-error statuses, so Run also records it
+// as the "operation.status_description" span attribute to keep it visible.
+//
+// Example:
+//
+//	s.SetStatus(codes.Ok, "cache hit")
+func (s State) SetStatus(code codes.Code, description string) {
+	if s.status == nil {
+		// Not created by Run; there is nobody to defer to, so apply it directly.
+		s.span.SetStatus(code, description)
+		return
+	}
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	s.status.set = true
+	s.status.code = code
+	s.status.description = description
+}
+
+// SetOutcome records the semantic outcome of the operation beyond success or failure,
+// e.g. "created", "updated" or "noop", or "hit" and "miss" for a cache lookup. It sets the
+// "operation.outcome" span attribute, and Run adds it as the "outcome" attribute of the
+// biz.operation.duration histogram, whose count then gives e.g. the hit ratio per operation
+// without a custom counter. Use a small fixed set of values: each is a new time series.
+// The last call wins.
+//
+// Example:
+//
+//	s.SetOutcome("hit")
+func (s State) SetOutcome(outcome string) {
+	s.span.SetAttributes(attribute.String("operation.outcome", outcome))
+	if s.status == nil {
+		// Not created by Run; there is no operation metric to add it to.
+		return
+	}
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	s.status.outcome = outcome
+}
+
+// Span starts a child span named name under the span of ctx, and returns a Context carrying
+// it and a function ending it. The Context logger gets the trace and span IDs of the child span.
+// It is a lighter alternative to a nested o11y.Run for a discrete step: no metrics are recorded
+// and errors are not captured, so use Run when the step can fail on its own.
+// ctx should be the Context passed to the Run closure, or one derived from it.
+//
+// Example:
+//
+//	ctx, end := s.Span(ctx, "render")
+//	html := render(ctx, page)
+//	end()
+func (s State) Span(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := s.childTracer().Start(ctx, name)
+	spansStarted.Add(1)
+	ctx, _ = contextWithSpanLogger(ctx, uncorrelatedLogger(ctx), span.SpanContext(), s.operation)
+	return ctx, func() { span.End() }
+}
+
+// childTracer returns the tracer child spans are started with: the one of Run, or the
+// package-level Tracer for a State not created by Run.
+func (s State) childTracer() trace.Tracer {
+	if s.tracer == nil {
+		return Tracer
+	}
+	return s.tracer
+}
+
 // AddEvent records a timestamped event on the current span's timeline.
 func (s State) AddEvent(name string, attributes ...attribute.KeyValue) {
 	s.span.AddEvent(name, trace.WithAttributes(attributes...))
 }
 
+// LogEvent writes a log entry at the given level and records the same message as an event
+// on the current span, so the trace timeline tells the same story as the logs.
+// The attributes are added both as log fields and as event attributes, and the event also
+// carries a "log.severity" attribute. The span event is recorded even if the log level
+// is disabled, keeping traces self-documenting regardless of log verbosity.
+//
+// Example:
+//
+//	s.LogEvent(zerolog.InfoLevel, "cache miss", attribute.String("key", key))
+func (s State) LogEvent(level zerolog.Level, msg string, attributes ...attribute.KeyValue) {
+	if e := s.Log.WithLevel(level); e != nil {
+		for _, attr := range attributes {
+			e = e.Interface(string(attr.Key), attr.Value.AsInterface())
+		}
+		e.Msg(msg)
+	}
+
+	eventAttrs := make([]attribute.KeyValue, 0, len(attributes)+1)
+	eventAttrs = append(eventAttrs, attributes...)
+	eventAttrs = append(eventAttrs, attribute.String("log.severity", level.String()))
+	s.span.AddEvent(msg, trace.WithAttributes(eventAttrs...))
+}
+
+// LogError logs err at error level together with its unwrapped causes
+// in the "error_chain" field, see WithErrorChain. A nil err is ignored.
+func (s State) LogError(err error) {
+	if err == nil {
+		return
+	}
+	WithErrorChain(s.Log.Error(), err).Msg("Operation error")
+}
+
 // IncCounter increments a pre-registered counter metric by 1.
 // This is the standard way to count occurrences of an event, such as a cache hit or a login attempt.
 // The metric name must correspond to a counter pre-registered in the metric_registry.
@@ -84,7 +242,18 @@ func (s State) AddEvent(name string, attributes ...attribute.KeyValue) {
 //
 //	s.IncCounter("cache.client.operation.total", attribute.String("result", "hit"))
 func (s State) IncCounter(name string, attributes ...attribute.KeyValue) {
-	AddToIntCounter(s.ctx, name, 1, attributes...)
+	AddToIntCounter(s.ctx, name, 1, s.withMetricAttributes(attributes)...)
+}
+
+// Record records a value on a pre-registered metric of any kind, see o11y.Record.
+// Use it when the instrument type doesn't matter to the call site.
+//
+// Example:
+//
+//	s.Record("cache.client.operation.total", 1, attribute.String("result", "hit"))
+//	s.Record("db.client.query.duration", time.Since(start).Seconds())
+func (s State) Record(name string, value float64, attributes ...attribute.KeyValue) {
+	Record(s.ctx, name, value, s.withMetricAttributes(attributes)...)
 }
 
 // RecordHistogram records a value in a pre-registered histogram metric.
@@ -99,5 +268,15 @@ func (s State) IncCounter(name string, attributes ...attribute.KeyValue) {
 //	duration := time.Since(startTime).Seconds()
 //	s.RecordHistogram("db.client.query.duration", duration, attribute.String("db.table", "users"))
 func (s State) RecordHistogram(name string, value float64, attributes ...attribute.KeyValue) {
-	RecordInFloat64Histogram(s.ctx, name, value, attributes...)
+	RecordInFloat64Histogram(s.ctx, name, value, s.withMetricAttributes(attributes)...)
+}
+
+// RecordIntHistogram records a value in a pre-registered integer histogram metric,
+// see RegisterInt64Histogram. Use it for naturally integer distributions, such as sizes in bytes.
+//
+// Example:
+//
+//	s.RecordIntHistogram("http.client.response.size", int64(len(body)))
+func (s State) RecordIntHistogram(name string, value int64, attributes ...attribute.KeyValue) {
+	RecordInInt64Histogram(s.ctx, name, value, s.withMetricAttributes(attributes)...)
 }
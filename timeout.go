@@ -0,0 +1,65 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// timeoutBody is the response body written when a request exceeds its timeout.
+const timeoutBody = `{"code":"TIMEOUT","message":"Request Timeout"}`
+
+// TimeoutMiddleware cancels the request context of handlers running longer than d and responds
+// with a 503. A timeout increments http.server.timeout.total and is recorded as a
+// "http.server.timeout" event on the current span. Handlers must still honor the context
+// to stop working; their writes after the timeout are discarded.
+//
+// Place it inside Handler (or MetricsMiddleware and RecoveryMiddleware), so the 503 is captured
+// by the metrics and panics of the handler, which are re-raised on the serving goroutine, are recovered:
+//
+//	server.Handler = o11y.Handler(cfg)(o11y.TimeoutMiddleware(5*time.Second)(mux))
+//
+// It is built on http.TimeoutHandler, so the ResponseWriter passed to the handler does not
+// support http.Flusher or http.Hijacker. The options are used to compute the http.route attribute.
+func TimeoutMiddleware(d time.Duration, opts ...HandlerOption) func(http.Handler) http.Handler {
+	o := newHandlerOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The deadline is also set here, so that it can be checked once http.TimeoutHandler
+			// returns: it does not wait for the handler goroutine, whose state is unreliable.
+			// TimeoutHandler derives its own deadline from this context, which is never earlier.
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			// TimeoutHandler passes a copy of the request to next, on which the router records
+			// the matched route for the outer middlewares.
+			routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				serveRouted(next, w, r)
+			})
+			m := httpsnoop.CaptureMetrics(http.TimeoutHandler(routed, d, timeoutBody), w, r.WithContext(ctx))
+
+			// A canceled parent context means the client went away, which is not a timeout.
+			// A handler responding with its own 503 just before the deadline is not one either.
+			timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Context().Err() == nil
+			if !timedOut || m.Code != http.StatusServiceUnavailable {
+				return
+			}
+
+			attrs := []attribute.KeyValue{
+				attribute.String("http.method", r.Method),
+				attribute.String("http.route", o.route(r)),
+			}
+			AddToIntCounter(r.Context(), "http.server.timeout.total", 1, attrs...)
+			trace.SpanFromContext(r.Context()).AddEvent("http.server.timeout",
+				trace.WithAttributes(attribute.String("http.server.timeout", d.String())))
+			GetLoggerFromContext(r.Context()).Warn().
+				Dur("timeout", d).
+				Msg("HTTP request timed out")
+		})
+	}
+}
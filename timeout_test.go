@@ -0,0 +1,68 @@
+package o11y
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	var counted []string
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		mu.Lock()
+		defer mu.Unlock()
+		counted = append(counted, name)
+	}
+
+	cfg := Config{Enabled: true, Service: "test-service"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := Handler(cfg)(TimeoutMiddleware(20 * time.Millisecond)(mux))
+
+	// A slow handler is cut off with a 503 and counted as a timeout.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "TIMEOUT")
+	assert.Equal(t, []string{"http.server.timeout.total", "http.server.request.total"}, counted)
+
+	// A fast handler is unaffected.
+	counted = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"http.server.request.total"}, counted)
+
+	// A handler responding with its own 503 in time is not a timeout.
+	counted = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unavailable", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, []string{"http.server.request.total"}, counted)
+
+	// Panics are re-raised and handled by the recovery middleware.
+	counted = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, []string{"http.server.request.total"}, counted)
+}
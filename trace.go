@@ -2,10 +2,19 @@ package o11y
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
@@ -13,6 +22,8 @@ import (
 	tc "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // setupTracing initializes and configures the global TracerProvider based on the TraceConfig.
@@ -20,32 +31,68 @@ import (
 // into a TracerProvider that is then set as the global default for the application.
 // It returns the configured provider and its corresponding shutdown function.
 func setupTracing(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider, ShutdownFunc, error) {
-	// 1. Handle the Enabled switch. If disabled, install a no-op provider and return.
+	// 1. Set the global TextMapPropagator.
+	// This is crucial for distributed tracing. It enables the automatic injection and extraction
+	// of Trace Context (TraceID, SpanID) and Baggage via HTTP/gRPC headers.
+	// Without this, traces will be broken when crossing service boundaries.
+	// It is set even when tracing is disabled, so baggage (e.g. for tenant routing) and
+	// upstream trace contexts keep propagating through this service.
+	if !cfg.skipGlobals {
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		))
+	}
+
+	// Forget the spans buffered by a previous initialization.
+	debugSpans.Store(nil)
+
+	// 2. Handle the Enabled switch. If disabled, install a no-op provider and return.
 	if !cfg.Enabled {
 		tp := tc.NewTracerProvider(tc.WithResource(res))
-		otel.SetTracerProvider(tp)
+		if !cfg.skipGlobals {
+			otel.SetTracerProvider(tp)
+		}
 		// Return a no-op shutdown function.
 		return tp, func(context.Context) error { return nil }, nil
 	}
 
-	// 2. Create the appropriate SpanExporter based on the configuration.
+	// 3. Create the appropriate SpanExporter based on the configuration.
 	var exporter tc.SpanExporter
 	var err error
+	// traceFile is the file written by the "file" exporter, closed on shutdown.
+	var traceFile *lumberjack.Logger
 
 	switch cfg.Exporter {
 	case "otlp-grpc":
 		log.Info().Msgf("Initializing OTLP gRPC trace exporter with endpoint: %s", cfg.Endpoint)
-		grpcOpts := []otlptracegrpc.Option{
-			otlptracegrpc.WithEndpoint(cfg.Endpoint),
-		}
+		grpcOpts := otlpEndpointOptions(cfg.Endpoint)
 		if cfg.OtlpInsecure {
 			grpcOpts = append(grpcOpts, otlptracegrpc.WithInsecure())
 			log.Warn().Msg("OTLP trace exporter is using an insecure gRPC connection.")
 		}
 		exporter, err = otlptracegrpc.New(context.Background(), grpcOpts...)
+		if err == nil {
+			// Surface export failures as metrics, since OTel only reports them to its error handler.
+			exporter = newCountingSpanExporter(exporter, cfg.Exporter)
+		}
 	case "stdout":
 		log.Info().Msg("Initializing stdout trace exporter.")
 		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, nil, fmt.Errorf("trace exporter %s requires a file path", cfg.Exporter)
+		}
+		log.Info().Msgf("Initializing file trace exporter writing to: %s", cfg.FilePath)
+		traceFile = &lumberjack.Logger{
+			Filename:   cfg.FilePath,
+			MaxSize:    cfg.FileRotation.MaxSize,
+			MaxBackups: cfg.FileRotation.MaxBackups,
+			MaxAge:     cfg.FileRotation.MaxAge,
+			Compress:   cfg.FileRotation.Compress,
+		}
+		// Without pretty printing, every span is encoded as a single JSON line.
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(traceFile))
 	default: // "none" or any other value
 		// This exporter discards all traces. It's useful for enabling the tracing API
 		// for testing purposes without actually exporting any data.
@@ -57,43 +104,309 @@ func setupTracing(cfg TraceConfig, res *resource.Resource) (trace.TracerProvider
 		return nil, nil, fmt.Errorf("failed to create trace exporter %s: %w", cfg.Exporter, err)
 	}
 
-	// 3. Configure the sampler based on the specified ratio.
+	// 4. Configure the sampler based on the specified ratio.
 	// The sampler decides whether a trace should be recorded and exported.
 	var sampler tc.Sampler
-	if cfg.SampleRatio >= 1.0 {
+	ratio := clampSampleRatio(cfg.SampleRatio)
+	if ratio >= 1.0 {
 		sampler = tc.AlwaysSample()
 		log.Info().Msg("Trace sampling is enabled for all traces (SampleRatio >= 1.0).")
-	} else if cfg.SampleRatio <= 0.0 {
+	} else if ratio <= 0.0 {
 		sampler = tc.NeverSample()
 		log.Info().Msg("Trace sampling is disabled for all traces (SampleRatio <= 0.0).")
 	} else {
-		sampler = tc.TraceIDRatioBased(cfg.SampleRatio)
-		log.Info().Msgf("Trace sampling is configured with a %.2f ratio.", cfg.SampleRatio)
+		sampler = tc.TraceIDRatioBased(ratio)
+		log.Info().Msgf("Trace sampling is configured with a %.2f ratio.", ratio)
 	}
+	// Requests marked by ForceSampleMiddleware bypass the ratio.
+	sampler = forceSampler{base: sampler}
 
-	// 4. Create the TracerProvider.
+	// 5. Create the TracerProvider.
 	// This is the core of the tracing SDK, which wires together the exporter, sampler, and resource.
 	// We use a BatchSpanProcessor for performance, as it batches spans before sending them to the exporter.
-	tp := tc.NewTracerProvider(
-		tc.WithBatcher(exporter),
+	// The sampling processor stamps the sampling reason on root spans, including those
+	// created by otelhttp/otelgrpc, to demystify missing traces in backends.
+	tpOpts := []tc.TracerProviderOption{
+		tc.WithSpanProcessor(samplingProcessor{ratio: ratio}),
 		tc.WithResource(res),
 		tc.WithSampler(sampler),
-	)
+		tc.WithSpanLimits(spanLimits(cfg.SpanLimits)),
+	}
+	if cfg.IDGenerator != nil {
+		tpOpts = append(tpOpts, tc.WithIDGenerator(cfg.IDGenerator))
+	}
+	if keys := validBaggageKeys(cfg.BaggageSpanAttributes); len(keys) > 0 {
+		tpOpts = append(tpOpts, tc.WithSpanProcessor(baggageProcessor{keys: keys, missing: baggageDefault(cfg.BaggageSpanAttributesDefault)}))
+	}
+	if len(cfg.SpanAttributes) > 0 {
+		tpOpts = append(tpOpts, tc.WithSpanProcessor(attributesProcessor{attrs: stringAttributes(cfg.SpanAttributes)}))
+	}
+	if cfg.DebugBufferSize > 0 {
+		buf := newSpanBuffer(cfg.DebugBufferSize)
+		debugSpans.Store(buf)
+		tpOpts = append(tpOpts, tc.WithSpanProcessor(buf))
+	}
+	for _, sp := range cfg.SpanProcessors {
+		tpOpts = append(tpOpts, tc.WithSpanProcessor(sp))
+	}
+	// The exporter comes last, so spans are exported once every processor has seen them.
+	tpOpts = append(tpOpts, tc.WithBatcher(exporter))
+	tp := tc.NewTracerProvider(tpOpts...)
 
-	// 5. Set the global TracerProvider.
+	// 6. Set the global TracerProvider.
 	// This makes the configured provider available to the entire application via otel.GetTracerProvider().
-	otel.SetTracerProvider(tp)
-
-	// 6. Set the global TextMapPropagator.
-	// This is crucial for distributed tracing. It enables the automatic injection and extraction
-	// of Trace Context (TraceID, SpanID) and Baggage via HTTP/gRPC headers.
-	// Without this, traces will be broken when crossing service boundaries.
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	if !cfg.skipGlobals {
+		otel.SetTracerProvider(tp)
+	}
 
 	// 7. Return the provider and its shutdown function.
 	// The shutdown function ensures that the batch processor is flushed before the application exits.
+	if traceFile != nil {
+		return tp, func(ctx context.Context) error {
+			// Flush the remaining spans before closing the file they are written to.
+			return errors.Join(tp.Shutdown(ctx), traceFile.Close())
+		}, nil
+	}
 	return tp, tp.Shutdown, nil
 }
+
+// Default span limits, applied when the corresponding SpanLimitsConfig field is zero.
+const (
+	defaultMaxSpanAttributes       = 128
+	defaultMaxAttributeValueLength = 1024
+	defaultMaxSpanEvents           = 128
+	defaultMaxSpanLinks            = 128
+)
+
+// spanLimits converts the configured limits to SDK span limits, filling in the defaults.
+func spanLimits(cfg SpanLimitsConfig) tc.SpanLimits {
+	limits := tc.NewSpanLimits()
+	limits.AttributeCountLimit = limitOrDefault(cfg.MaxAttributes, defaultMaxSpanAttributes)
+	limits.AttributeValueLengthLimit = limitOrDefault(cfg.MaxAttributeValueLength, defaultMaxAttributeValueLength)
+	limits.EventCountLimit = limitOrDefault(cfg.MaxEvents, defaultMaxSpanEvents)
+	limits.LinkCountLimit = limitOrDefault(cfg.MaxLinks, defaultMaxSpanLinks)
+	return limits
+}
+
+// limitOrDefault returns def for a zero limit and -1 (unlimited) for a negative one.
+func limitOrDefault(limit, def int) int {
+	switch {
+	case limit == 0:
+		return def
+	case limit < 0:
+		return -1
+	default:
+		return limit
+	}
+}
+
+// unixEndpointScheme is the Endpoint prefix selecting a Unix domain socket instead of TCP.
+const unixEndpointScheme = "unix://"
+
+// otlpEndpointOptions returns the exporter options targeting endpoint.
+func otlpEndpointOptions(endpoint string) []otlptracegrpc.Option {
+	target, dialer := otlpTarget(endpoint)
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(target)}
+	if dialer != nil {
+		opts = append(opts, otlptracegrpc.WithDialOption(dialer))
+	}
+	return opts
+}
+
+// otlpTarget returns the gRPC target of an OTLP endpoint and, if needed, the dial option reaching it.
+// A "unix:///path/to/socket" endpoint dials the socket directly, which is how sidecar
+// collectors are commonly exposed; anything else is treated as a regular host:port.
+func otlpTarget(endpoint string) (string, grpc.DialOption) {
+	socket, ok := strings.CutPrefix(endpoint, unixEndpointScheme)
+	if !ok {
+		return endpoint, nil
+	}
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	// The passthrough resolver hands the target to the dialer unchanged; the dialer ignores it anyway.
+	return "passthrough:///" + socket, grpc.WithContextDialer(dialer)
+}
+
+// exporterHealth makes the health of the telemetry pipeline itself observable. Every failed
+// export increments otlp.exporter.export.failures, and otlp.exporter.connected reflects whether
+// the last export succeeded (1) or failed (0). Both are tagged with the exporter and signal.
+type exporterHealth struct {
+	attrs     []attribute.KeyValue
+	connected atomic.Bool
+}
+
+// newExporterHealth returns the health of the named exporter of signal, e.g. "traces".
+func newExporterHealth(name, signal string) exporterHealth {
+	return exporterHealth{attrs: []attribute.KeyValue{
+		attribute.String("exporter", name),
+		attribute.String("signal", signal),
+	}}
+}
+
+// record records the outcome of an export.
+func (h *exporterHealth) record(ctx context.Context, err error) {
+	if err != nil {
+		AddToIntCounter(ctx, "otlp.exporter.export.failures", 1, h.attrs...)
+	}
+
+	// The UpDownCounter only moves on state transitions, so its value acts as a 0/1 gauge.
+	connected := err == nil
+	if h.connected.Swap(connected) != connected {
+		delta := int64(1)
+		if !connected {
+			delta = -1
+		}
+		AddToInt64UpDownCounter(ctx, "otlp.exporter.connected", delta, h.attrs...)
+	}
+}
+
+// countingSpanExporter decorates a SpanExporter to record its exporterHealth.
+type countingSpanExporter struct {
+	tc.SpanExporter
+	health exporterHealth
+}
+
+// newCountingSpanExporter wraps exporter, tagging its metrics with the exporter name.
+func newCountingSpanExporter(exporter tc.SpanExporter, name string) *countingSpanExporter {
+	return &countingSpanExporter{SpanExporter: exporter, health: newExporterHealth(name, "traces")}
+}
+
+// ExportSpans exports the spans and records the outcome.
+func (e *countingSpanExporter) ExportSpans(ctx context.Context, spans []tc.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.health.record(ctx, err)
+	return err
+}
+
+// clampSampleRatio returns ratio limited to [0, 1], logging a warning if it was out of range.
+func clampSampleRatio(ratio float64) float64 {
+	clamped, ok := effectiveSampleRatio(ratio)
+	if !ok {
+		log.Warn().Msgf("Invalid trace sample ratio %v, must be between 0 and 1; using %v.", ratio, clamped)
+	}
+	return clamped
+}
+
+// effectiveSampleRatio returns ratio limited to [0, 1], and whether it was in range.
+// NaN, e.g. from a bad templated config, is treated as 0 like an unset ratio.
+func effectiveSampleRatio(ratio float64) (float64, bool) {
+	switch {
+	case math.IsNaN(ratio), ratio < 0:
+		return 0, false
+	case ratio > 1:
+		return 1, false
+	default:
+		return ratio, true
+	}
+}
+
+// samplingProcessor is a SpanProcessor that records the head-sampling configuration on local root spans
+// as "sampling.ratio", and why the span was sampled as "sampling.reason": "forced" for requests marked
+// by ForceSampleMiddleware, "ratio" otherwise. Only sampled spans reach a processor, since the configured
+// samplers never record without sampling.
+type samplingProcessor struct {
+	ratio float64
+}
+
+// OnStart stamps the sampling attributes on spans without a local parent.
+func (p samplingProcessor) OnStart(parent context.Context, s tc.ReadWriteSpan) {
+	if s.Parent().IsValid() && !s.Parent().IsRemote() {
+		return
+	}
+	reason := "ratio"
+	if isForceSampled(parent) {
+		reason = "forced"
+	}
+	s.SetAttributes(
+		attribute.Float64("sampling.ratio", p.ratio),
+		attribute.String("sampling.reason", reason),
+	)
+}
+
+func (samplingProcessor) OnEnd(s tc.ReadOnlySpan)              {}
+func (samplingProcessor) Shutdown(ctx context.Context) error   { return nil }
+func (samplingProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+// attributesProcessor is a SpanProcessor that sets static attributes on every span.
+type attributesProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+// OnStart sets the static attributes.
+func (p attributesProcessor) OnStart(parent context.Context, s tc.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+func (attributesProcessor) OnEnd(s tc.ReadOnlySpan)              {}
+func (attributesProcessor) Shutdown(ctx context.Context) error   { return nil }
+func (attributesProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+// stringAttributes converts a map to attributes, sorted by key for a deterministic order.
+func stringAttributes(m map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		attrs = append(attrs, attribute.String(k, m[k]))
+	}
+	return attrs
+}
+
+// baggageProcessor is a SpanProcessor that copies an allow-list of baggage members
+// from the parent context onto each span as attributes.
+type baggageProcessor struct {
+	keys []string
+
+	// missing, if not empty, is the value of the keys absent from the baggage.
+	missing string
+}
+
+// OnStart sets an attribute for every allowed key present in the baggage.
+func (p baggageProcessor) OnStart(parent context.Context, s tc.ReadWriteSpan) {
+	s.SetAttributes(baggageAttributes(parent, p.keys, p.missing)...)
+}
+
+// baggageDefault returns the configured value for absent baggage keys, "unknown" if unset.
+func baggageDefault(missing string) string {
+	if missing == "" {
+		return "unknown"
+	}
+	return missing
+}
+
+// baggageAttributes returns an attribute for each of keys present in the baggage of ctx.
+// Absent keys get the missing value, or no attribute if it is empty.
+func baggageAttributes(ctx context.Context, keys []string, missing string) []attribute.KeyValue {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 && missing == "" {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		if m := bag.Member(key); m.Key() != "" {
+			attrs = append(attrs, attribute.String(key, m.Value()))
+		} else if missing != "" {
+			attrs = append(attrs, attribute.String(key, missing))
+		}
+	}
+	return attrs
+}
+
+// validBaggageKeys returns keys without those that can never be baggage keys,
+// which are logged as a configuration error.
+func validBaggageKeys(keys []string) []string {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, err := baggage.NewMember(key, "v"); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Ignoring invalid baggage key")
+			continue
+		}
+		valid = append(valid, key)
+	}
+	return valid
+}
+
+func (baggageProcessor) OnEnd(s tc.ReadOnlySpan)              {}
+func (baggageProcessor) Shutdown(ctx context.Context) error   { return nil }
+func (baggageProcessor) ForceFlush(ctx context.Context) error { return nil }
@@ -1,13 +1,29 @@
 package o11y
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/propagation"
+	mt "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
+	tc "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
 )
 
 // TestSetupTracing_Propagator verifies that the TextMapPropagator is correctly registered.
@@ -41,3 +57,357 @@ func TestSetupTracing_Propagator(t *testing.T) {
 	assert.Contains(t, fields, "traceparent", "Propagator should support 'traceparent' (TraceContext)")
 	assert.Contains(t, fields, "baggage", "Propagator should support 'baggage' (Baggage)")
 }
+
+// TestSetupTracing_PropagatorWhenDisabled verifies that baggage and trace context still
+// propagate when tracing is disabled.
+func TestSetupTracing_PropagatorWhenDisabled(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	_, shutdown, err := setupTracing(TraceConfig{Enabled: false}, resource.Default())
+	assert.NoError(t, err)
+	defer shutdown(context.Background())
+
+	fields := otel.GetTextMapPropagator().Fields()
+	assert.Contains(t, fields, "traceparent")
+	assert.Contains(t, fields, "baggage")
+}
+
+// failingSpanExporter is a SpanExporter whose export result can be toggled.
+type failingSpanExporter struct {
+	tracetest.NoopExporter
+	err error
+}
+
+func (e *failingSpanExporter) ExportSpans(ctx context.Context, spans []tc.ReadOnlySpan) error {
+	return e.err
+}
+
+// TestCountingSpanExporter verifies that export failures and connection state are recorded.
+func TestCountingSpanExporter(t *testing.T) {
+	resetMetricMocks()
+	defer resetMetricMocks()
+
+	var failures int64
+	var connected []int64
+	addToIntCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "otlp.exporter.export.failures" {
+			failures += value
+		}
+	}
+	addToInt64UpDownCounterFunc = func(ctx context.Context, name string, value int64, attributes ...attribute.KeyValue) {
+		if name == "otlp.exporter.connected" {
+			connected = append(connected, value)
+		}
+	}
+
+	inner := &failingSpanExporter{err: errors.New("connection refused")}
+	exporter := newCountingSpanExporter(inner, "otlp-grpc")
+	ctx := context.Background()
+
+	assert.Error(t, exporter.ExportSpans(ctx, nil))
+	assert.Error(t, exporter.ExportSpans(ctx, nil))
+	inner.err = nil
+	assert.NoError(t, exporter.ExportSpans(ctx, nil))
+	assert.NoError(t, exporter.ExportSpans(ctx, nil))
+	inner.err = errors.New("unavailable")
+	assert.Error(t, exporter.ExportSpans(ctx, nil))
+
+	assert.Equal(t, int64(3), failures)
+	// Only transitions are recorded: connected once, then disconnected once.
+	assert.Equal(t, []int64{1, -1}, connected)
+}
+
+// TestSamplingProcessor verifies that only root spans are stamped with the sampling attributes.
+func TestSamplingProcessor(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := tc.NewTracerProvider(
+		tc.WithSpanProcessor(samplingProcessor{ratio: 0.25}),
+		tc.WithSpanProcessor(sr),
+	)
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+	_, forced := tracer.Start(withForceSample(context.Background()), "forced")
+	forced.End()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	assert.NotContains(t, attributeKeys(spans[0].Attributes()), attribute.Key("sampling.ratio"))
+	assert.Contains(t, spans[1].Attributes(), attribute.Float64("sampling.ratio", 0.25))
+	assert.Contains(t, spans[1].Attributes(), attribute.String("sampling.reason", "ratio"))
+	assert.Contains(t, spans[2].Attributes(), attribute.String("sampling.reason", "forced"))
+}
+
+// TestClampSampleRatio verifies that out-of-range and NaN ratios are clamped instead of reaching the sampler.
+func TestClampSampleRatio(t *testing.T) {
+	assert.Equal(t, 0.5, clampSampleRatio(0.5))
+	assert.Equal(t, 1.0, clampSampleRatio(1))
+	assert.Equal(t, 0.0, clampSampleRatio(-0.1))
+	assert.Equal(t, 1.0, clampSampleRatio(2))
+	assert.Equal(t, 0.0, clampSampleRatio(math.NaN()))
+
+	tp, shutdown, err := setupTracing(TraceConfig{Enabled: true, Exporter: "none", SampleRatio: math.NaN()}, resource.Empty())
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+	_, span := tp.Tracer("test").Start(context.Background(), "op")
+	span.End()
+	assert.False(t, span.SpanContext().IsSampled())
+}
+
+// costProcessor is a custom SpanProcessor setting an attribute derived from the span name.
+type costProcessor struct{}
+
+func (costProcessor) OnStart(parent context.Context, s tc.ReadWriteSpan) {
+	if s.Name() == "expensive" {
+		s.SetAttributes(attribute.Int("cost.unit", 10))
+	}
+}
+func (costProcessor) OnEnd(s tc.ReadOnlySpan)              {}
+func (costProcessor) Shutdown(ctx context.Context) error   { return nil }
+func (costProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+// TestSetupTracing_SpanProcessors verifies that custom processors are added to the provider in order.
+func TestSetupTracing_SpanProcessors(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp, shutdown, err := setupTracing(TraceConfig{
+		Enabled:        true,
+		Exporter:       "none",
+		SampleRatio:    1,
+		SpanProcessors: []tc.SpanProcessor{costProcessor{}, sr},
+	}, resource.Empty())
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "expensive")
+	span.End()
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("cost.unit", 10))
+}
+
+// TestBaggageProcessor verifies that only allow-listed baggage members are copied onto spans.
+func TestBaggageProcessor(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := tc.NewTracerProvider(
+		tc.WithSpanProcessor(baggageProcessor{keys: []string{"tenant_id", "region"}}),
+		tc.WithSpanProcessor(sr),
+	)
+	tracer := tp.Tracer("test")
+
+	tenant, err := baggage.NewMember("tenant_id", "acme")
+	require.NoError(t, err)
+	secret, err := baggage.NewMember("session", "s3cr3t")
+	require.NoError(t, err)
+	bag, err := baggage.New(tenant, secret)
+	require.NoError(t, err)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	_, span := tracer.Start(ctx, "with-baggage")
+	span.End()
+	_, span = tracer.Start(context.Background(), "without-baggage")
+	span.End()
+
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("tenant_id", "acme"))
+	assert.NotContains(t, attributeKeys(spans[0].Attributes()), attribute.Key("session"))
+	assert.NotContains(t, attributeKeys(spans[0].Attributes()), attribute.Key("region"))
+	assert.Empty(t, spans[1].Attributes())
+}
+
+// TestBaggageRegion covers routing by a single "region" baggage member, copied onto every
+// span and metric, with "unknown" when the request carries no region.
+func TestBaggageRegion(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp, shutdown, err := setupTracing(TraceConfig{
+		Enabled:               true,
+		Exporter:              "none",
+		SampleRatio:           1,
+		BaggageSpanAttributes: []string{"region"},
+		SpanProcessors:        []tc.SpanProcessor{sr},
+	}, resource.Default())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+	tracer := tp.Tracer("test")
+
+	reader := mt.NewManualReader()
+	original := Meter
+	Meter = mt.NewMeterProvider(mt.WithReader(reader)).Meter("test")
+	t.Cleanup(func() {
+		Meter = original
+		metricDefaults.Store(nil)
+	})
+	setMetricDefaults(MetricConfig{BaggageAttributes: []string{"region"}})
+	require.NoError(t, RegisterInt64Counter("routing.requests.total", "desc", "1"))
+
+	region, err := baggage.NewMember("region", "eu-west")
+	require.NoError(t, err)
+	bag, err := baggage.New(region)
+	require.NoError(t, err)
+	for _, ctx := range []context.Context{baggage.ContextWithBaggage(context.Background(), bag), context.Background()} {
+		ctx, span := tracer.Start(ctx, "route")
+		AddToIntCounter(ctx, "routing.requests.total", 1)
+		span.End()
+	}
+
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("region", "eu-west"))
+	assert.Contains(t, spans[1].Attributes(), attribute.String("region", "unknown"))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	var regions []string
+	for _, dp := range sum.DataPoints {
+		v, _ := dp.Attributes.Value("region")
+		regions = append(regions, v.AsString())
+	}
+	assert.ElementsMatch(t, []string{"eu-west", "unknown"}, regions)
+
+	// Keys that can never be baggage keys are dropped.
+	assert.Equal(t, []string{"region"}, validBaggageKeys([]string{"region", "not a key"}))
+}
+
+// TestAttributesProcessor verifies that configured span attributes are set on every span.
+func TestAttributesProcessor(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	attrs := stringAttributes(map[string]string{"deployment.canary": "true", "deployment.cohort": "b"})
+	tp := tc.NewTracerProvider(
+		tc.WithSpanProcessor(attributesProcessor{attrs: attrs}),
+		tc.WithSpanProcessor(sr),
+	)
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("deployment.canary", "true"),
+		attribute.String("deployment.cohort", "b"),
+	}, attrs)
+	for _, span := range sr.Ended() {
+		assert.Equal(t, attrs, span.Attributes())
+	}
+}
+
+func attributeKeys(attrs []attribute.KeyValue) []attribute.Key {
+	keys := make([]attribute.Key, 0, len(attrs))
+	for _, kv := range attrs {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+// fakeTraceCollector is an OTLP trace service that reports every received request.
+type fakeTraceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	received chan *collectortrace.ExportTraceServiceRequest
+}
+
+func (c *fakeTraceCollector) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.received <- req
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// TestSetupTracing_UnixSocket verifies that spans are exported to a collector listening on a Unix socket.
+func TestSetupTracing_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otlp.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+
+	collector := &fakeTraceCollector{received: make(chan *collectortrace.ExportTraceServiceRequest, 1)}
+	srv := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(srv, collector)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cfg := TraceConfig{
+		Enabled:      true,
+		Exporter:     "otlp-grpc",
+		Endpoint:     "unix://" + socket,
+		OtlpInsecure: true,
+		SampleRatio:  1.0,
+	}
+	tp, shutdown, err := setupTracing(cfg, resource.Default())
+	assert.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(context.Background(), "over-unix-socket")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	select {
+	case req := <-collector.received:
+		spans := req.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()
+		assert.Equal(t, "over-unix-socket", spans[0].GetName())
+	default:
+		t.Fatal("collector did not receive any spans")
+	}
+}
+
+// TestSpanLimits verifies that defaults are applied and oversized attributes are truncated.
+func TestSpanLimits(t *testing.T) {
+	limits := spanLimits(SpanLimitsConfig{MaxEvents: 1, MaxLinks: -5})
+	assert.Equal(t, 128, limits.AttributeCountLimit)
+	assert.Equal(t, 1024, limits.AttributeValueLengthLimit)
+	assert.Equal(t, 1, limits.EventCountLimit)
+	assert.Equal(t, -1, limits.LinkCountLimit)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := tc.NewTracerProvider(
+		tc.WithSpanProcessor(sr),
+		tc.WithSpanLimits(spanLimits(SpanLimitsConfig{MaxAttributeValueLength: 4})),
+	)
+	_, span := tp.Tracer("test").Start(context.Background(), "limited")
+	span.SetAttributes(attribute.String("payload", "0123456789"))
+	span.End()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("payload", "0123"))
+}
+
+// TestSetupTracing_FileExporter verifies that the file exporter writes one JSON line per span.
+func TestSetupTracing_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	cfg := TraceConfig{
+		Enabled:     true,
+		Exporter:    "file",
+		FilePath:    path,
+		SampleRatio: 1.0,
+	}
+	tp, shutdown, err := setupTracing(cfg, resource.Default())
+	assert.NoError(t, err)
+
+	tracer := tp.Tracer("test")
+	for _, name := range []string{"first", "second"} {
+		_, span := tracer.Start(context.Background(), name)
+		span.End()
+	}
+	assert.NoError(t, shutdown(context.Background()))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span struct{ Name string }
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		names = append(names, span.Name)
+	}
+	assert.Equal(t, []string{"first", "second"}, names)
+
+	// A file path is required.
+	_, _, err = setupTracing(TraceConfig{Enabled: true, Exporter: "file"}, resource.Default())
+	assert.Error(t, err)
+}
@@ -13,8 +13,11 @@ import (
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
@@ -200,3 +203,48 @@ func NewHTTPClient(transport http.RoundTripper) *http.Client {
 		Transport: instrumentedTransport,
 	}
 }
+
+// NewReverseProxyTransport returns an `http.RoundTripper` for `httputil.ReverseProxy.Transport`
+// that instruments proxied requests like NewHTTPClient does: every request to the upstream gets
+// a client span named "proxy <method>", the trace context is injected into its headers, and the
+// otelhttp http.client.* metrics are recorded with the upstream host and port as the
+// server.address and server.port attributes.
+//
+// The client span is a child of the span in the request context, e.g. the server span of a
+// gateway handler wrapped with o11y.Handler. If there is none, the span continues the trace
+// propagated by the incoming request headers, which the proxy copies to the outgoing request,
+// so the gateway does not break the trace of its callers.
+//
+// The global OpenTelemetry providers are captured when the transport is created, so create it
+// after o11y.Init. If the `transport` argument is nil, `http.DefaultTransport` will be used.
+//
+// Usage:
+//
+//	proxy := httputil.NewSingleHostReverseProxy(upstream)
+//	proxy.Transport = o11y.NewReverseProxyTransport(nil)
+func NewReverseProxyTransport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return proxyTransport{next: otelhttp.NewTransport(transport,
+		// Without a provider, otelhttp takes the one of the span in the context, which is a
+		// no-op for a trace continued from the headers.
+		otelhttp.WithTracerProvider(otel.GetTracerProvider()),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "proxy " + r.Method
+		}),
+	)}
+}
+
+// proxyTransport continues the trace of the proxied request headers when the request context has none.
+type proxyTransport struct {
+	next http.RoundTripper
+}
+
+func (t proxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(r.Context()).IsValid() {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		r = r.WithContext(ctx)
+	}
+	return t.next.RoundTrip(r)
+}
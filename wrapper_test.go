@@ -1,10 +1,22 @@
 package o11y
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	mt "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNewHTTPClient(t *testing.T) {
@@ -19,3 +31,59 @@ func TestNewHTTPClient(t *testing.T) {
 	assert.NotNil(t, client2)
 	assert.NotEqual(t, customTr, client2.Transport, "Transport should be wrapped")
 }
+
+func TestNewReverseProxyTransport(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	reader := mt.NewManualReader()
+	oldTP, oldMP, oldProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetMeterProvider(mt.NewMeterProvider(mt.WithReader(reader)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetMeterProvider(oldMP)
+		otel.SetTextMapPropagator(oldProp)
+	})
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = NewReverseProxyTransport(nil)
+	gateway := httptest.NewServer(proxy)
+	defer gateway.Close()
+
+	// The gateway handler has no span of its own: the caller's trace is continued.
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest(http.MethodGet, gateway.URL+"/orders", nil)
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The span ends when the proxy closes the upstream response body, after it was copied.
+	require.Eventually(t, func() bool { return len(sr.Ended()) == 1 }, time.Second, time.Millisecond)
+	spans := sr.Ended()
+	assert.Equal(t, "proxy GET", spans[0].Name())
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, "00-"+traceID+"-"+spans[0].SpanContext().SpanID().String()+"-01", upstreamTraceparent)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var found bool
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "http.client.request.duration" {
+			found = true
+			dp := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+			host, _ := dp.Attributes.Value("server.address")
+			assert.Equal(t, target.Hostname(), host.AsString())
+		}
+	}
+	assert.True(t, found)
+}